@@ -0,0 +1,122 @@
+package arrival
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/pburakov/playback/input"
+)
+
+// Supported inter-arrival gap distributions.
+const (
+	Exponential = "exponential"
+	LogNormal   = "lognormal"
+	Empirical   = "empirical"
+)
+
+// Distribution draws inter-arrival gaps between consecutive messages.
+type Distribution interface {
+	// Gap returns the next random inter-arrival gap drawn from the given
+	// random source.
+	Gap(r *rand.Rand) time.Duration
+}
+
+type exponential struct {
+	mean float64
+}
+
+// NewExponential returns exponentially distributed gaps with the given mean
+// rate in messages per second, which results in Poisson arrivals.
+func NewExponential(rate float64) (Distribution, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("invalid rate %v", rate)
+	}
+	return &exponential{mean: 1 / rate}, nil
+}
+
+func (d *exponential) Gap(r *rand.Rand) time.Duration {
+	return seconds(r.ExpFloat64() * d.mean)
+}
+
+type logNormal struct {
+	mu    float64
+	sigma float64
+}
+
+// NewLogNormal returns log-normally distributed gaps with the given mean rate
+// in messages per second and the shape parameter sigma (standard deviation of
+// the gap logarithm).
+func NewLogNormal(rate float64, sigma float64) (Distribution, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("invalid rate %v", rate)
+	}
+	if sigma <= 0 {
+		return nil, fmt.Errorf("invalid sigma %v", sigma)
+	}
+	// Mean of the log-normal distribution is exp(mu + sigma^2/2)
+	return &logNormal{mu: math.Log(1/rate) - sigma*sigma/2, sigma: sigma}, nil
+}
+
+func (d *logNormal) Gap(r *rand.Rand) time.Duration {
+	return seconds(math.Exp(d.mu + d.sigma*r.NormFloat64()))
+}
+
+type empirical struct {
+	gaps  []time.Duration
+	scale float64
+}
+
+// NewEmpirical returns gaps drawn from the given observed gaps. If the rate is
+// positive, gaps are scaled so that the mean rate matches the given rate in
+// messages per second, otherwise the observed mean rate is kept.
+func NewEmpirical(gaps []time.Duration, rate float64) (Distribution, error) {
+	if len(gaps) == 0 {
+		return nil, errors.New("no observed gaps")
+	}
+	var total time.Duration
+	for _, g := range gaps {
+		total += g
+	}
+	d := &empirical{gaps: gaps, scale: 1}
+	if rate > 0 {
+		if total == 0 {
+			return nil, errors.New("observed gaps are all zero, unable to scale to the target rate")
+		}
+		mean := total.Seconds() / float64(len(gaps))
+		d.scale = 1 / rate / mean
+	}
+	return d, nil
+}
+
+func (d *empirical) Gap(r *rand.Rand) time.Duration {
+	return time.Duration(float64(d.gaps[r.Intn(len(d.gaps))]) * d.scale)
+}
+
+// Learn reads all timestamps from the input reader and returns the gaps
+// between consecutive timestamps. The input data must be sorted by timestamp,
+// negative gaps are ignored.
+func Learn(in input.FileReader) ([]time.Duration, error) {
+	var gaps []time.Duration
+	var prev time.Time
+	for i := 0; ; i++ {
+		ts, _, e := in.ReadLineWithTS()
+		if e == io.EOF {
+			return gaps, nil
+		}
+		if e != nil {
+			return nil, e
+		}
+		if i > 0 && !ts.Before(prev) {
+			gaps = append(gaps, ts.Sub(prev))
+		}
+		prev = ts
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
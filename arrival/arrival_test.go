@@ -0,0 +1,81 @@
+package arrival
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pburakov/playback/input/csv"
+	"github.com/stretchr/testify/assert"
+)
+
+const samples = 100000
+
+func TestExponential(t *testing.T) {
+	d, e := NewExponential(100)
+
+	assert.NoError(t, e)
+	assert.InDelta(t, 10*time.Millisecond, mean(d), float64(500*time.Microsecond))
+}
+
+func TestLogNormal(t *testing.T) {
+	d, e := NewLogNormal(100, 0.5)
+
+	assert.NoError(t, e)
+	assert.InDelta(t, 10*time.Millisecond, mean(d), float64(500*time.Microsecond))
+}
+
+func TestEmpirical(t *testing.T) {
+	gaps := []time.Duration{time.Second, 3 * time.Second}
+
+	d, e := NewEmpirical(gaps, 0)
+
+	assert.NoError(t, e)
+	r := testRand()
+	for i := 0; i < 100; i++ {
+		g := d.Gap(r)
+		assert.True(t, g == time.Second || g == 3*time.Second)
+	}
+
+	// Scaled to 10 msg/s
+	d, e = NewEmpirical(gaps, 10)
+
+	assert.NoError(t, e)
+	assert.InDelta(t, 100*time.Millisecond, mean(d), float64(5*time.Millisecond))
+}
+
+func TestLearn(t *testing.T) {
+	in, _ := csv.Init("../input/csv/input_test.csv", "baz", "2006-01-02 15:04:05 UTC")
+
+	gaps, e := Learn(in)
+
+	assert.NoError(t, e)
+	assert.Equal(t, []time.Duration{2*24*time.Hour + 15*time.Hour + 37*time.Minute + 12*time.Second}, gaps)
+}
+
+func TestErrors(t *testing.T) {
+	_, e := NewExponential(0)
+	assert.Error(t, e)
+
+	_, e = NewLogNormal(10, 0)
+	assert.Error(t, e)
+
+	_, e = NewEmpirical(nil, 10)
+	assert.Error(t, e)
+
+	_, e = NewEmpirical([]time.Duration{0, 0}, 10)
+	assert.Error(t, e)
+}
+
+func testRand() *rand.Rand {
+	return rand.New(rand.NewSource(42))
+}
+
+func mean(d Distribution) float64 {
+	r := testRand()
+	var total time.Duration
+	for i := 0; i < samples; i++ {
+		total += d.Gap(r)
+	}
+	return float64(total) / samples
+}
@@ -0,0 +1,76 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testTime = time.Date(2019, 02, 11, 15, 20, 9, 0, time.UTC)
+
+func TestFake(t *testing.T) {
+	f := NewFake(testTime)
+
+	assert.Equal(t, testTime, f.Now())
+
+	t1 := f.NewTimer(time.Second)
+	t2 := f.NewTimer(time.Minute)
+	t3 := f.NewTimer(time.Hour)
+
+	assert.Equal(t, 3, f.Waiters())
+	assert.True(t, t3.Stop())
+	assert.False(t, t3.Stop())
+
+	f.Advance(time.Second)
+
+	assert.Equal(t, testTime.Add(time.Second), <-t1.C())
+	assert.Equal(t, 1, f.Waiters())
+
+	f.Advance(time.Hour)
+
+	assert.Equal(t, testTime.Add(time.Hour+time.Second), <-t2.C())
+	assert.Equal(t, 0, f.Waiters())
+	assert.Len(t, t3.C(), 0)
+}
+
+func TestFakeImmediate(t *testing.T) {
+	f := NewFake(testTime)
+
+	assert.Equal(t, testTime, <-f.NewTimer(0).C())
+	assert.Equal(t, testTime, <-f.NewTimer(-time.Second).C())
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(testTime)
+	done := make(chan bool)
+
+	go func() {
+		<-f.NewTimer(time.Second).C()
+		done <- true
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+
+	assert.True(t, <-done)
+}
+
+func TestVirtual(t *testing.T) {
+	v := NewVirtual(testTime)
+
+	assert.Equal(t, testTime.Add(time.Hour), <-v.NewTimer(time.Hour).C())
+	assert.Equal(t, testTime.Add(time.Hour), v.Now())
+	assert.Equal(t, testTime.Add(time.Hour), <-v.NewTimer(-time.Second).C())
+	assert.False(t, v.NewTimer(time.Second).Stop())
+	assert.Equal(t, testTime.Add(time.Hour+time.Second), v.Now())
+}
+
+func TestReal(t *testing.T) {
+	start := time.Now()
+
+	<-Real.NewTimer(10 * time.Millisecond).C()
+
+	assert.True(t, Real.Now().Sub(start) >= 10*time.Millisecond)
+	assert.True(t, Real.NewTimer(time.Hour).Stop())
+}
@@ -2,22 +2,44 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/pubsub"
+	"github.com/pburakov/playback"
+	"github.com/pburakov/playback/arrival"
 	"github.com/pburakov/playback/config"
+	"github.com/pburakov/playback/filter"
 	"github.com/pburakov/playback/input"
 	"github.com/pburakov/playback/input/avro"
 	"github.com/pburakov/playback/input/csv"
+	"github.com/pburakov/playback/input/generate"
 	"github.com/pburakov/playback/input/json"
+	"github.com/pburakov/playback/input/loop"
 	"github.com/pburakov/playback/output"
+	"github.com/pburakov/playback/output/amqp"
+	"github.com/pburakov/playback/output/file"
+	"github.com/pburakov/playback/output/grpc"
+	"github.com/pburakov/playback/output/mqtt"
+	"github.com/pburakov/playback/output/nats"
+	"github.com/pburakov/playback/output/redis"
+	"github.com/pburakov/playback/output/sqs"
+	"github.com/pburakov/playback/output/webhook"
+	"github.com/pburakov/playback/profile"
+	"github.com/pburakov/playback/rewrite"
 	"github.com/pburakov/playback/runner"
+	"github.com/pburakov/playback/sample"
+	"github.com/pburakov/playback/transform"
 	"github.com/pburakov/playback/util"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/option"
+	ggrpc "google.golang.org/grpc"
 )
 
 // Playback program runner.
@@ -25,18 +47,160 @@ func main() {
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 	log.SetOutput(os.Stdout)
 
-	rand.Seed(time.Now().Unix())
+	cs := config.Init()
 
-	c := config.Init()
+	for _, c := range cs {
+		if c.Output == config.StdoutOutput {
+			// keep stdout for the messages
+			log.SetOutput(os.Stderr)
+		}
+	}
+
+	for _, c := range cs {
+		if c.Seed == 0 {
+			c.Seed = time.Now().UnixNano()
+		}
+	}
+
+	sums := make([]*summary, len(cs))
+	rands := make([]*rand.Rand, len(cs))
+	ins := make([]input.FileReader, len(cs))
+	outs := make([]output.Sink, len(cs))
+	for i, c := range cs {
+		sums[i] = &summary{stream: c.Name}
+		rands[i] = rand.New(rand.NewSource(c.Seed))
+		ins[i] = initReader(c, sums[i], rands[i])
+		outs[i] = initOutput(ins[i], c)
+	}
+
+	sched := initSchedule(cs)
+	ps := make([]*playback.Player, len(cs))
+	for i, c := range cs {
+		ps[i] = initPlayer(ins[i], outs[i], c, rands[i], sched[i]...)
+	}
+
+	stats := initPlayback(ps)
+	closeAll()
+	for i, s := range sums {
+		s.print(stats[i])
+	}
+}
+
+var (
+	// files holds the file sinks by path, shared by the streams writing to the
+	// same file. Empty path stands for stdout.
+	files = make(map[string]*file.File)
+	// closers holds the sinks and readers closed once the playback is stopped.
+	closers []io.Closer
+)
+
+// initReader opens the input file reader. If the input is configured to be
+// played more than once, the reader is wrapped into a looping reader.
+// Configured record filter, sampling and field transformations are applied on
+// top. Random values are drawn from the given source of the stream.
+func initReader(c *config.ProgramConfig, sum *summary, rnd *rand.Rand) input.FileReader {
+	var r input.FileReader
+	var e error
+	if c.Loop == 1 {
+		r, e = openReader(c, rnd)
+	} else {
+		r, e = initLoop(c, rnd)
+	}
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	r = wrapReader(r, c, sum, rnd)
+	if cl, ok := r.(io.Closer); ok {
+		closers = append(closers, cl)
+	}
+	return r
+}
+
+// wrapReader wraps the reader into the configured filtering, sampling and
+// transformation stages.
+func wrapReader(r input.FileReader, c *config.ProgramConfig, sum *summary, rnd *rand.Rand) input.FileReader {
+	return initTransform(initSample(initFilter(r, c, sum), c, sum, rnd), c)
+}
 
-	in := initReader(c)
-	t := initTopic(c)
-	out := initOutput(t, c)
+// initFilter wraps the reader into a filtering reader, if a filter expression
+// is configured. The number of filtered records is added to the summary.
+func initFilter(r input.FileReader, c *config.ProgramConfig, sum *summary) input.FileReader {
+	if len(c.Filter) == 0 {
+		return r
+	}
+	x, e := filter.Parse(c.Filter)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	f, e := filter.Init(r, x)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	sum.add("records filtered", f.Filtered)
+	sum.add("filter evaluation errors", f.Errors)
+	return f
+}
+
+// initSample wraps the reader into a sampling reader, if sampling is configured.
+// The number of skipped records is added to the summary.
+func initSample(r input.FileReader, c *config.ProgramConfig, sum *summary, rnd *rand.Rand) input.FileReader {
+	if c.Sample == 1 && len(c.SampleKey) == 0 {
+		return r
+	}
+	s, e := sample.Init(r, c.Sample, c.SampleKey, rnd)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	sum.add("records sampled out", s.Skipped)
+	return s
+}
+
+// initTransform wraps the reader into a transformation stage, if any field
+// transformations are configured.
+func initTransform(r input.FileReader, c *config.ProgramConfig) input.FileReader {
+	rules := &transform.Rules{
+		Keep:   c.Keep,
+		Drop:   c.Drop,
+		Rename: c.Rename,
+		Set:    c.Set,
+		Hash:   c.Hash,
+		Salt:   c.HashSalt,
+		Redact: c.Redact,
+	}
+	if rules.Empty() {
+		return r
+	}
+	t, e := transform.Init(r, rules)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	return t
+}
 
-	initPlayback(in, out, c)
+// initLoop constructs a looping reader, with optional payload timestamp rewriting.
+func initLoop(c *config.ProgramConfig, rnd *rand.Rand) (input.FileReader, error) {
+	r, e := loop.Init(func() (input.FileReader, error) {
+		return openReader(c, rnd)
+	}, c.Loop, c.LoopGap)
+	if e != nil {
+		return nil, e
+	}
+	if c.LoopRewriteTS {
+		if e := r.RewriteTS(c.TSColumn, c.TSFormat); e != nil {
+			return nil, e
+		}
+	}
+	return r, nil
 }
 
-func initReader(c *config.ProgramConfig) input.FileReader {
+// openReader opens a new reader for the configured input file type. Generated
+// input draws random values from the given source.
+func openReader(c *config.ProgramConfig, rnd *rand.Rand) (input.FileReader, error) {
 	var r input.FileReader
 	var e error
 	switch c.FileType {
@@ -49,60 +213,560 @@ func initReader(c *config.ProgramConfig) input.FileReader {
 	case config.JSON:
 		r, e = json.Init(c.FilePath, c.TSColumn, c.TSFormat)
 		break
+	case config.Template:
+		r, e = generate.Init(c.FilePath, c.TSFormat, c.Count, rnd)
+		break
 	default:
 		e = fmt.Errorf("error initializing reader for type %q", c.FileType)
 	}
 	if e != nil {
-		util.Fatal(e)
-		return nil
+		return nil, e
 	}
-	return r
+	return r, nil
 }
 
-// initTopic constructs PubSub clients, verifies if given PubSub topic exists
-// and constructs Topic instance.
+// initTopic constructs PubSub clients, verifies if given PubSub topic exists,
+// creating it if configured, and constructs Topic instance with the configured
+// publish settings.
 func initTopic(c *config.ProgramConfig) *pubsub.Topic {
 	ctx1, c1 := context.WithTimeout(context.Background(), c.Timeout)
 	defer c1()
-	p, e := pubsub.NewClient(ctx1, c.ProjectID)
+	p, e := pubsub.NewClient(ctx1, c.ProjectID, initEmulator(c)...)
 	if e != nil {
 		util.Fatal(e)
 		return nil
 	}
-	t := p.Topic(c.Topic)
 	ctx2, c2 := context.WithTimeout(context.Background(), c.Timeout)
 	defer c2()
-	if b, e := t.Exists(ctx2); e != nil || !b {
-		util.Fatal(errors.New("topic does not exist or unexpected pubsub error"))
+	t, e := output.InitTopic(ctx2, p, c.Topic, c.PubSubCreateTopic)
+	if e != nil {
+		util.Fatal(e)
 		return nil
 	}
+	if len(c.PubSubCreateSubscription) > 0 {
+		if _, e := output.InitSubscription(ctx2, p, t, c.PubSubCreateSubscription); e != nil {
+			util.Fatal(e)
+			return nil
+		}
+	}
+	t.PublishSettings.CountThreshold = int(c.PubSubBatchCount)
+	t.PublishSettings.ByteThreshold = int(c.PubSubBatchBytes)
+	t.PublishSettings.DelayThreshold = c.PubSubBatchDelay
+	if c.PubSubGoroutines > 0 {
+		t.PublishSettings.NumGoroutines = int(c.PubSubGoroutines)
+	}
 	return t
 }
 
-// initPlayback initiates configured playback mode. Log messages are printed before
-// and after the playback is performed.
-func initPlayback(in input.FileReader, out func(string, []byte), c *config.ProgramConfig) {
+// initEmulator returns the PubSub client options connecting to the emulator,
+// if configured.
+func initEmulator(c *config.ProgramConfig) []option.ClientOption {
+	if len(c.PubSubEmulator) == 0 {
+		return nil
+	}
+	conn, e := ggrpc.Dial(c.PubSubEmulator, ggrpc.WithInsecure())
+	if e != nil {
+		util.Fatal(fmt.Errorf("error connecting to pubsub emulator: %s", e))
+		return nil
+	}
+	closers = append(closers, conn)
+	log.Printf("Using PubSub emulator at %s", c.PubSubEmulator)
+	return []option.ClientOption{option.WithGRPCConn(conn)}
+}
+
+// initPlayer constructs the player for the configured playback mode, drawing
+// random values from the given source shared with the input reader of the
+// stream. Extra options are applied last.
+func initPlayer(in input.FileReader, out output.Sink, c *config.ProgramConfig, rnd *rand.Rand, extra ...playback.Option) *playback.Player {
+	opts := []playback.Option{
+		playback.WithReader(in),
+		playback.WithSink(out),
+		playback.WithRand(rnd),
+		initMode(c),
+		initAmplify(in, c),
+	}
+	p, e := playback.New(append(opts, extra...)...)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	return p
+}
+
+// initMode returns the player option for configured playback mode.
+func initMode(c *config.ProgramConfig) playback.Option {
 	switch c.Mode {
 	case config.Instant:
 		log.Printf("Starting playback in instant mode...")
-		runner.PlayInstant(in, out)
+		return playback.WithInstant()
 	case config.Paced:
 		log.Printf("Starting playback in paced mode...")
-		runner.PlayPaced(in, out, c.Delay, c.MaxJitterMSec)
+		return playback.WithPaced(c.Delay, c.MaxJitterMSec)
 	case config.Relative:
 		log.Printf("Starting playback in relative mode...")
-		runner.PlayRelative(in, out, c.Window, c.MaxJitterMSec)
+		return playback.WithRelative(c.Window, c.MaxJitterMSec)
+	case config.Simulated:
+		log.Printf("Starting playback in simulated mode...")
+		return playback.WithSimulated(c.Window, c.MaxJitterMSec, c.SimAttribute)
+	case config.Profiled:
+		p, e := profile.Parse(c.Profile)
+		if e != nil {
+			util.Fatal(e)
+			return nil
+		}
+		log.Printf("Starting playback in profiled mode...")
+		return playback.WithProfile(p.Rate)
+	case config.Statistical:
+		d := initDistribution(c)
+		log.Printf("Starting playback in statistical mode (%s distribution)...", c.Distribution)
+		return playback.WithStatistical(d.Gap)
 	default:
 		util.Fatal(fmt.Errorf("unknown mode %d", c.Mode))
-		return
+		return nil
+	}
+}
+
+// initPlayback runs the players concurrently until all playbacks are completed
+// or interrupted, and returns the stats of every player. The first failing
+// player stops all others. Log messages are printed before and after the
+// playback is performed.
+func initPlayback(ps []*playback.Player) []playback.Stats {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		log.Print("Interrupting playback...")
+		cancel()
+	}()
+
+	stats := make([]playback.Stats, len(ps))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, p := range ps {
+		i, p := i, p
+		g.Go(func() error {
+			var e error
+			stats[i], e = p.Play(gctx)
+			return e
+		})
+	}
+	if e := g.Wait(); e != nil && e != context.Canceled {
+		util.Fatal(e)
+		return stats
 	}
 
 	log.Print("Playback stopped")
+	return stats
+}
+
+// initSchedule returns the player options scheduling the start of every
+// stream. The playback is delayed until the configured start time. In relative
+// and simulated modes, the configured origin timestamp is mapped to the start
+// time. Without an origin, timelines of multiple streams are aligned, so that
+// relative timing of events across the streams is kept: the earliest first
+// event timestamp of all streams played back in relative or simulated mode is
+// mapped to the start time.
+func initSchedule(cs []*config.ProgramConfig) [][]playback.Option {
+	now := time.Now()
+	origin := time.Time{}
+	if len(cs) > 1 {
+		origin = initOrigin(cs)
+	}
+
+	opts := make([][]playback.Option, len(cs))
+	for i, c := range cs {
+		start := now
+		if !c.StartAt.IsZero() {
+			start = c.StartAt
+			opts[i] = append(opts[i], playback.WithStartAt(start))
+			log.Printf("Playback is scheduled to start at %s", start)
+		}
+		o := origin
+		if !c.Origin.IsZero() {
+			o = c.Origin
+		}
+		if !o.IsZero() {
+			opts[i] = append(opts[i], playback.WithAnchor(o, start))
+			log.Printf("Event timestamp %s is mapped to %s", o, start)
+		}
+	}
+	return opts
+}
+
+// initOrigin returns the earliest first event timestamp of all streams played
+// back in relative or simulated mode, or zero time if there are none.
+func initOrigin(cs []*config.ProgramConfig) time.Time {
+	var origin time.Time
+	for _, c := range cs {
+		if (c.Mode != config.Relative && c.Mode != config.Simulated) || c.FileType == config.Template {
+			continue
+		}
+		r, e := openReader(c, rand.New(rand.NewSource(c.Seed)))
+		if e != nil {
+			util.Fatal(e)
+			return origin
+		}
+		ts, _, e := r.ReadLineWithTS()
+		input.Close(r)
+		if e == io.EOF {
+			continue
+		}
+		if e != nil {
+			util.Fatal(fmt.Errorf("stream %q: %s", c.Name, e))
+			return origin
+		}
+		if origin.IsZero() || ts.Before(origin) {
+			origin = ts
+		}
+	}
+	if !origin.IsZero() {
+		log.Printf("Aligning %d streams to the earliest event timestamp %s", len(cs), origin)
+	}
+	return origin
+}
+
+// initDistribution constructs the inter-arrival gap distribution for the
+// statistical playback. Empirical distribution is learned from a separate
+// pass over the input file.
+func initDistribution(c *config.ProgramConfig) arrival.Distribution {
+	var d arrival.Distribution
+	var e error
+	switch c.Distribution {
+	case arrival.Exponential:
+		d, e = arrival.NewExponential(c.Rate)
+	case arrival.LogNormal:
+		d, e = arrival.NewLogNormal(c.Rate, c.Sigma)
+	case arrival.Empirical:
+		// gaps are learned from the records that are played back
+		rnd := rand.New(rand.NewSource(c.Seed))
+		var r input.FileReader
+		if r, e = openReader(c, rnd); e != nil {
+			break
+		}
+		r = wrapReader(r, c, new(summary), rnd)
+		var gaps []time.Duration
+		gaps, e = arrival.Learn(r)
+		input.Close(r)
+		if e != nil {
+			break
+		}
+		log.Printf("Learned %d inter-arrival gaps from the input records", len(gaps))
+		d, e = arrival.NewEmpirical(gaps, c.Rate)
+	default:
+		e = fmt.Errorf("unknown distribution %q", c.Distribution)
+	}
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	return d
+}
+
+// initOutput returns preconfigured output sink. If timestamp rewriting is
+// enabled, the payload is updated with the send time right before publishing.
+func initOutput(in input.FileReader, c *config.ProgramConfig) output.Sink {
+	var s output.Sink
+	switch c.Output {
+	case config.PubSubOutput:
+		s = initPubSub(c)
+	case config.HTTPOutput:
+		s = initWebhook(in, c)
+	case config.StdoutOutput, config.FileOutput:
+		s = initFile(in, c)
+	case config.NATSOutput:
+		s = initNATS(in, c)
+	case config.RedisOutput:
+		s = initRedis(in, c)
+	case config.MQTTOutput:
+		s = initMQTT(in, c)
+	case config.AMQPOutput:
+		s = initAMQP(in, c)
+	case config.GRPCOutput:
+		s = initGRPC(in, c)
+	case config.SQSOutput:
+		s = initSQS(in, c)
+	default:
+		util.Fatal(fmt.Errorf("unknown output %q", c.Output))
+		return nil
+	}
+	if c.RewriteTS {
+		s = initRewriter(in, c).Wrap(s)
+	}
+	return s
+}
+
+// initAmplify returns the player option publishing each record multiple times.
+func initAmplify(in input.FileReader, c *config.ProgramConfig) playback.Option {
+	var mutate func([]byte, uint) ([]byte, error)
+	if len(c.AmplifyID) > 0 {
+		p, ok := in.(input.CodecProvider)
+		if !ok || p.Codec() == nil {
+			util.Fatal(fmt.Errorf("id mutation is not supported for type %q", c.FileType))
+			return nil
+		}
+		var e error
+		if mutate, e = runner.IDMutator(p.Codec(), c.AmplifyID, c.AmplifyIDMode, c.Seed); e != nil {
+			util.Fatal(e)
+			return nil
+		}
+	}
+	if c.Amplify > 1 {
+		log.Printf("Each record is published %d times", c.Amplify)
+	}
+	return playback.WithAmplify(c.Amplify, mutate)
+}
+
+// initPubSub constructs PubSub sink.
+func initPubSub(c *config.ProgramConfig) output.Sink {
+	p := output.InitPubSub(initTopic(c), c.Timeout)
+	p.Limit(int(c.PubSubMaxOutstanding), int(c.PubSubMaxOutstandingBytes))
+	closers = append(closers, p)
+	log.Printf("Publishing messages to PubSub topic %s in batches of up to %d", c.Topic, c.PubSubBatchCount)
+	return p
+}
+
+// initWebhook constructs HTTP sink.
+func initWebhook(in input.FileReader, c *config.ProgramConfig) output.Sink {
+	w := webhook.Init(c.HTTPURL, contentType(c.HTTPContentType, c), codec(in), c.Timeout)
+	w.Method(c.HTTPMethod)
+	for k, v := range c.HTTPHeaders {
+		if e := w.Header(k, v); e != nil {
+			util.Fatal(e)
+			return nil
+		}
+	}
+	w.Retries(int(c.HTTPRetries))
+	w.Limit(int(c.HTTPConcurrency))
+	if c.HTTPBatch > 1 {
+		w.Batch(int(c.HTTPBatch), c.HTTPBatchDelay)
+	}
+	log.Printf("Sending messages to %s", c.HTTPURL)
+	return w
+}
+
+// initFile constructs the sink writing messages to stdout or to the output
+// file, or returns the existing one.
+func initFile(in input.FileReader, c *config.ProgramConfig) output.Sink {
+	path := ""
+	if c.Output == config.FileOutput {
+		path = c.OutputFile
+	}
+	if f, found := files[path]; found {
+		return f
+	}
+	var f *file.File
+	if len(path) == 0 {
+		log.Printf("Writing messages to stdout")
+		f = file.Init(os.Stdout, codec(in))
+	} else {
+		var e error
+		if f, e = file.Open(path, codec(in)); e != nil {
+			util.Fatal(e)
+			return nil
+		}
+		log.Printf("Writing messages to %q", path)
+	}
+	files[path] = f
+	closers = append(closers, f)
+	return f
+}
+
+// initNATS constructs NATS sink.
+func initNATS(in input.FileReader, c *config.ProgramConfig) output.Sink {
+	n, e := nats.Init(c.NATSURL, c.NATSSubject, codec(in), c.Timeout)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	if c.NATSJetStream {
+		if e := n.JetStream(c.NATSMsgID); e != nil {
+			util.Fatal(e)
+			return nil
+		}
+	}
+	closers = append(closers, n)
+	log.Printf("Publishing messages to NATS subject %q", c.NATSSubject)
+	return n
+}
+
+// initRedis constructs Redis Streams sink.
+func initRedis(in input.FileReader, c *config.ProgramConfig) output.Sink {
+	r, e := redis.Init(c.RedisURL, c.RedisStream, codec(in), c.Timeout)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	r.Payload(c.RedisPayloadField)
+	r.Fields(c.RedisFields...)
+	r.MaxLen(int64(c.RedisMaxLen), c.RedisMaxLenApprox)
+	r.Pipeline(int(c.RedisPipeline), c.RedisPipelineDelay)
+	closers = append(closers, r)
+	log.Printf("Adding messages to Redis stream %q", c.RedisStream)
+	return r
+}
+
+// initMQTT constructs MQTT sink.
+func initMQTT(in input.FileReader, c *config.ProgramConfig) output.Sink {
+	m, e := mqtt.Init(c.MQTTBroker, c.MQTTTopic, codec(in), c.Timeout)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	if len(c.MQTTClientID) > 0 {
+		m.ClientID(c.MQTTClientID)
+	}
+	if len(c.MQTTUsername) > 0 {
+		m.Credentials(c.MQTTUsername, c.MQTTPassword)
+	}
+	t, e := util.TLSConfig(c.MQTTCAFile, c.MQTTCertFile, c.MQTTKeyFile, c.MQTTInsecureSkipVerify)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	m.TLS(t)
+	if e := m.QoS(byte(c.MQTTQoS)); e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	m.Retained(c.MQTTRetained)
+	if e := m.Connect(); e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	closers = append(closers, m)
+	log.Printf("Publishing messages to MQTT topic %q", c.MQTTTopic)
+	return m
 }
 
-// initOutput returns preconfigured publishing action function.
-func initOutput(t *pubsub.Topic, c *config.ProgramConfig) func(string, []byte) {
-	return func(tag string, d []byte) {
-		output.Publish(t, tag, d, c.Timeout)
+// initAMQP constructs AMQP sink.
+func initAMQP(in input.FileReader, c *config.ProgramConfig) output.Sink {
+	a, e := amqp.Init(c.AMQPURL, c.AMQPExchange, c.AMQPRoutingKey, codec(in), c.Timeout)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	for k, v := range c.AMQPHeaders {
+		if e := a.Header(k, v); e != nil {
+			util.Fatal(e)
+			return nil
+		}
+	}
+	if c.AMQPConfirm {
+		if e := a.Confirm(); e != nil {
+			util.Fatal(e)
+			return nil
+		}
+	}
+	a.ContentType(contentType(c.AMQPContentType, c))
+	a.Persistent(c.AMQPPersistent)
+	closers = append(closers, a)
+	log.Printf("Publishing messages to AMQP exchange %q", c.AMQPExchange)
+	return a
+}
+
+// initGRPC constructs gRPC sink.
+func initGRPC(in input.FileReader, c *config.ProgramConfig) output.Sink {
+	g, e := grpc.Init(c.GRPCTarget, c.GRPCDescriptorSet, c.GRPCMethod, codec(in), c.Timeout)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	for k, v := range c.GRPCMetadata {
+		if e := g.Metadata(k, v); e != nil {
+			util.Fatal(e)
+			return nil
+		}
+	}
+	if c.GRPCTLS {
+		t, e := util.TLSConfig(c.GRPCCAFile, "", "", c.GRPCInsecureSkipVerify)
+		if e != nil {
+			util.Fatal(e)
+			return nil
+		}
+		g.TLS(t)
+	}
+	g.DiscardUnknown(c.GRPCDiscardUnknown)
+	if e := g.Connect(); e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	closers = append(closers, g)
+	log.Printf("Calling gRPC method %s at %s", c.GRPCMethod, c.GRPCTarget)
+	return g
+}
+
+// initSQS constructs SQS sink.
+func initSQS(in input.FileReader, c *config.ProgramConfig) output.Sink {
+	s, e := sqs.Init(c.SQSQueueURL, c.SQSRegion, c.SQSEndpoint, codec(in), c.Timeout)
+	if e != nil {
+		util.Fatal(e)
+		return nil
+	}
+	if len(c.SQSGroupID) > 0 {
+		if e := s.GroupID(c.SQSGroupID); e != nil {
+			util.Fatal(e)
+			return nil
+		}
+	}
+	if len(c.SQSDedupID) > 0 {
+		if e := s.DeduplicationID(c.SQSDedupID); e != nil {
+			util.Fatal(e)
+			return nil
+		}
+	}
+	if c.SQSBatch > 1 {
+		if e := s.Batch(int(c.SQSBatch), c.SQSBatchDelay); e != nil {
+			util.Fatal(e)
+			return nil
+		}
+	}
+	log.Printf("Sending messages to SQS queue %s", c.SQSQueueURL)
+	return s
+}
+
+// contentType returns the configured content type, or the one derived from
+// the input format.
+func contentType(ct string, c *config.ProgramConfig) string {
+	if len(ct) > 0 {
+		return ct
+	}
+	if c.FileType == config.Avro {
+		return "avro/binary"
+	}
+	return "application/json"
+}
+
+// closeAll closes the sinks and readers holding files or connections, in the
+// reverse order, so that connections outlive the sinks using them.
+func closeAll() {
+	for i := len(closers) - 1; i >= 0; i-- {
+		if e := closers[i].Close(); e != nil {
+			log.Printf("Error closing: %s", e)
+		}
+	}
+}
+
+// codec returns the codec of the input reader, or nil if the reader doesn't
+// provide one.
+func codec(in input.FileReader) input.Codec {
+	if p, ok := in.(input.CodecProvider); ok {
+		return p.Codec()
+	}
+	return nil
+}
+
+// initRewriter constructs timestamp rewriter using the codec of the input reader.
+func initRewriter(in input.FileReader, c *config.ProgramConfig) *rewrite.Rewriter {
+	p, ok := in.(input.CodecProvider)
+	if !ok || p.Codec() == nil {
+		util.Fatal(fmt.Errorf("timestamp rewriting is not supported for type %q", c.FileType))
+		return nil
+	}
+	rw := rewrite.Init(p.Codec(), c.TSColumn, c.TSFormat, c.RewriteShift)
+	if e := rw.KeepOriginal(c.OrigTSColumn, c.OrigTSAttr); e != nil {
+		util.Fatal(e)
 	}
+	return rw
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pburakov/playback"
+)
+
+// summary collects input counters reported once the playback is stopped.
+type summary struct {
+	stream   string
+	names    []string
+	counters []func() uint64
+}
+
+// add registers a named counter.
+func (s *summary) add(name string, f func() uint64) {
+	s.names = append(s.names, name)
+	s.counters = append(s.counters, f)
+}
+
+// print logs the values of all counters, along with the player stats.
+func (s *summary) print(stats playback.Stats) {
+	vals := []string{
+		fmt.Sprintf("%d messages sent", stats.Sent),
+		fmt.Sprintf("%d failed", stats.Failed),
+	}
+	for i, f := range s.counters {
+		vals = append(vals, fmt.Sprintf("%d %s", f(), s.names[i]))
+	}
+	if len(s.stream) > 0 {
+		log.Printf("Summary of stream %q: %s", s.stream, strings.Join(vals, ", "))
+		return
+	}
+	log.Printf("Summary: %s", strings.Join(vals, ", "))
+}
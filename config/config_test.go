@@ -1 +1,70 @@
 package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"foo", "bar.baz"}, splitList("foo, bar.baz,"))
+	assert.Nil(t, splitList(""))
+}
+
+func TestParsePairs(t *testing.T) {
+	m, e := parsePairs([]string{"foo:bar", "baz:faz:moo"}, ":")
+
+	assert.NoError(t, e)
+	assert.Equal(t, map[string]string{"foo": "bar", "baz": "faz:moo"}, m)
+
+	_, e = parsePairs([]string{"foo"}, ":")
+
+	assert.Error(t, e)
+
+	_, e = parsePairs([]string{"=bar"}, "=")
+
+	assert.Error(t, e)
+}
+
+func TestParseTime(t *testing.T) {
+	ts, e := parseTime("start_at", "2026-10-18T15:00:00Z")
+
+	assert.NoError(t, e)
+	assert.Equal(t, time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC), ts)
+
+	ts, e = parseTime("start_at", "")
+
+	assert.NoError(t, e)
+	assert.True(t, ts.IsZero())
+
+	_, e = parseTime("start_at", "2026-10-18 15:00")
+
+	assert.Error(t, e)
+}
+
+func TestValidateInput(t *testing.T) {
+	p, ft, e := validateInput("../input/json/input_test.json", "")
+
+	assert.NoError(t, e)
+	assert.Equal(t, "../input/json/input_test.json", p)
+	assert.Equal(t, JSON, ft)
+
+	p, ft, e = validateInput("", "../input/generate/template_test.json")
+
+	assert.NoError(t, e)
+	assert.Equal(t, "../input/generate/template_test.json", p)
+	assert.Equal(t, Template, ft)
+
+	_, _, e = validateInput("../input/json/input_test.json", "../input/generate/template_test.json")
+
+	assert.Error(t, e)
+
+	_, _, e = validateInput("", "non_existent_file")
+
+	assert.Error(t, e)
+
+	_, _, e = validateInput("", "")
+
+	assert.Error(t, e)
+}
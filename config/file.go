@@ -0,0 +1,250 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of environment variables used as flag fallbacks,
+// e.g. PLAYBACK_PROJECT_ID for the project_id flag.
+const EnvPrefix = "PLAYBACK_"
+
+const (
+	// profilesKey is the config file section holding named profiles.
+	profilesKey = "profiles"
+	// streamsKey is the config file section holding named streams.
+	streamsKey = "streams"
+)
+
+// stream holds the config file settings of a single stream.
+type stream struct {
+	name   string
+	values map[string][]string
+}
+
+// loadFile reads the settings from the config file and returns values of the
+// flag set by flag name, along with the settings of the streams sorted by
+// name, if any. The format is detected by the file extension: YAML, TOML or
+// JSON. Keys are flag names. Settings of the named profile, if given, override
+// the top-level settings.
+func loadFile(fs *flag.FlagSet, path string, profile string) (map[string][]string, []stream, error) {
+	b, e := ioutil.ReadFile(path)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	var doc map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		e = yaml.Unmarshal(b, &doc)
+	case ".toml":
+		e = toml.Unmarshal(b, &doc)
+	case ".json":
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		e = d.Decode(&doc)
+	default:
+		return nil, nil, fmt.Errorf("unsupported config file format %q", ext)
+	}
+	if e != nil {
+		return nil, nil, fmt.Errorf("error parsing config file %q: %s", path, e)
+	}
+
+	profiles, e := section(doc, profilesKey)
+	if e != nil {
+		return nil, nil, e
+	}
+	streams, e := section(doc, streamsKey)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	values, e := flagValues(fs, doc)
+	if e != nil {
+		return nil, nil, e
+	}
+	if len(profile) > 0 {
+		p, found := profiles[profile].(map[string]interface{})
+		if !found {
+			return nil, nil, fmt.Errorf("profile %q is not defined in config file %q", profile, path)
+		}
+		overrides, e := flagValues(fs, p)
+		if e != nil {
+			return nil, nil, fmt.Errorf("invalid profile %q: %s", profile, e)
+		}
+		values = merge(values, overrides)
+	}
+
+	names := make([]string, 0, len(streams))
+	for n := range streams {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var l []stream
+	for _, n := range names {
+		m, found := streams[n].(map[string]interface{})
+		if !found {
+			return nil, nil, fmt.Errorf("unexpected settings of stream %q, expected a map", n)
+		}
+		v, e := flagValues(fs, m)
+		if e != nil {
+			return nil, nil, fmt.Errorf("invalid stream %q: %s", n, e)
+		}
+		l = append(l, stream{name: n, values: v})
+	}
+	return values, l, nil
+}
+
+// section removes the named section from the config file document and
+// returns it.
+func section(doc map[string]interface{}, name string) (map[string]interface{}, error) {
+	v, found := doc[name]
+	if !found {
+		return nil, nil
+	}
+	delete(doc, name)
+	m, found := v.(map[string]interface{})
+	if !found {
+		return nil, fmt.Errorf("unexpected %q section, expected a map", name)
+	}
+	return m, nil
+}
+
+// merge returns the union of the flag values, with the overrides taking
+// precedence.
+func merge(values map[string][]string, overrides map[string][]string) map[string][]string {
+	m := make(map[string][]string, len(values)+len(overrides))
+	for k, v := range values {
+		m[k] = v
+	}
+	for k, v := range overrides {
+		m[k] = v
+	}
+	return m
+}
+
+// flagValues converts the config file settings to flag values. Lists are
+// joined with commas, except for repeated flags receiving every element.
+// Maps are converted to key-value pairs in the format of the flag, name:value
+// for headers and metadata, path=value otherwise. Renames are applied in order,
+// which maps don't keep, so they must be listed as from:to pairs.
+func flagValues(fs *flag.FlagSet, m map[string]interface{}) (map[string][]string, error) {
+	values := make(map[string][]string, len(m))
+	for k, v := range m {
+		f := fs.Lookup(k)
+		if f == nil || k == "config" || k == "config_profile" {
+			return nil, fmt.Errorf("unknown setting %q", k)
+		}
+		_, repeated := f.Value.(*listFlag)
+
+		var l []string
+		switch v := v.(type) {
+		case []interface{}:
+			for _, i := range v {
+				l = append(l, scalar(i))
+			}
+		case map[string]interface{}:
+			if k == "rename" {
+				return nil, fmt.Errorf("setting %q requires a list of from:to pairs", k)
+			}
+			keys := make([]string, 0, len(v))
+			for kk := range v {
+				keys = append(keys, kk)
+			}
+			sort.Strings(keys)
+			sep := "="
+			if k == "http_header" || k == "amqp_header" || k == "grpc_metadata" {
+				sep = ":"
+			}
+			for _, kk := range keys {
+				l = append(l, kk+sep+scalar(v[kk]))
+			}
+		default:
+			l = []string{scalar(v)}
+		}
+		if !repeated && len(l) > 1 {
+			l = []string{strings.Join(l, ",")}
+		}
+		values[k] = l
+	}
+	return values, nil
+}
+
+// scalar formats a config file value as a flag value. Values other than
+// strings are formatted as JSON literals, null values are empty.
+func scalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	b, e := json.Marshal(v)
+	if e != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// applySettings sets the flags which were not given on the command line from
+// the config file values, falling back to environment variables.
+func applySettings(fs *flag.FlagSet, file map[string][]string, env func(string) (string, bool)) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	var e error
+	fs.VisitAll(func(f *flag.Flag) {
+		if e != nil || given[f.Name] {
+			return
+		}
+		values, found := file[f.Name]
+		if !found {
+			v, found := env(EnvPrefix + strings.ToUpper(f.Name))
+			if !found {
+				return
+			}
+			values = []string{v}
+		}
+		for _, v := range values {
+			if e = fs.Set(f.Name, v); e != nil {
+				e = fmt.Errorf("invalid value %q for setting %q: %s", v, f.Name, e)
+				return
+			}
+		}
+	})
+	return e
+}
+
+// resetFlags resets the flags of the flag set to their default values and
+// returns a new flag set holding the same flags, none of them set.
+func resetFlags(fs *flag.FlagSet) *flag.FlagSet {
+	n := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	fs.VisitAll(func(f *flag.Flag) {
+		if l, ok := f.Value.(*listFlag); ok {
+			*l = (*l)[:0]
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		n.Var(f.Value, f.Name, f.Usage)
+	})
+	return n
+}
+
+// lookupEnv returns the value of the environment variable, if it is set and
+// not empty.
+func lookupEnv(k string) (string, bool) {
+	v := os.Getenv(k)
+	return v, len(v) > 0
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Uint("mode", 0, "")
+	fs.String("input", "", "")
+	fs.String("topic", "", "")
+	fs.String("project_id", "", "")
+	fs.Uint("window", DefaultWindowMSec, "")
+	fs.String("keep", "", "")
+	fs.String("rename", "", "")
+	fs.Var(new(listFlag), "set", "")
+	fs.Var(new(listFlag), "http_header", "")
+	return fs
+}
+
+func TestLoadFile(t *testing.T) {
+	for _, f := range []string{"config_test.yaml", "config_test.toml", "config_test.json"} {
+		v, streams, e := loadFile(testFlagSet(), f, "")
+
+		assert.NoError(t, e, f)
+		assert.Equal(t, map[string][]string{
+			"mode":        {"2"},
+			"input":       {"orders.json"},
+			"window":      {"500"},
+			"keep":        {"id,user.id"},
+			"rename":      {"ts:created_at"},
+			"set":         {"source=replay", "version=2"},
+			"http_header": {"X-Source:replay"},
+		}, v, f)
+		assert.Empty(t, streams, f)
+
+		v, _, e = loadFile(testFlagSet(), f, "staging")
+
+		assert.NoError(t, e, f)
+		assert.Equal(t, []string{"orders-staging"}, v["topic"], f)
+		assert.Equal(t, []string{"1000"}, v["window"], f)
+		assert.Equal(t, []string{"orders.json"}, v["input"], f)
+
+		_, _, e = loadFile(testFlagSet(), f, "production")
+
+		assert.Error(t, e, f)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("input", "", "")
+
+	_, _, e := loadFile(fs, "config_test.yaml", "")
+
+	assert.Error(t, e)
+
+	_, _, e = loadFile(testFlagSet(), "config_test.go", "")
+
+	assert.Error(t, e)
+
+	_, _, e = loadFile(testFlagSet(), "missing.yaml", "")
+
+	assert.Error(t, e)
+
+	// renames are ordered, so a mapping is rejected
+	f, _ := ioutil.TempFile("", "config*.yaml")
+	_, _ = f.WriteString("rename:\n  ts: created_at\n")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+
+	_, _, e = loadFile(testFlagSet(), f.Name(), "")
+
+	assert.Error(t, e)
+}
+
+func TestLoadFileStreams(t *testing.T) {
+	v, streams, e := loadFile(testFlagSet(), "scenario_test.yaml", "staging")
+
+	assert.NoError(t, e)
+	assert.Equal(t, map[string][]string{"project_id": {"my-project-staging"}}, v)
+	assert.Equal(t, []stream{
+		{name: "clicks", values: map[string][]string{"input": {"clicks.json"}, "topic": {"clicks"}, "mode": {"0"}}},
+		{name: "orders", values: map[string][]string{"input": {"orders.avro"}, "topic": {"orders"}, "mode": {"2"}}},
+	}, streams)
+}
+
+func TestResetFlags(t *testing.T) {
+	fs := testFlagSet()
+	assert.NoError(t, fs.Parse([]string{"-window=100", "-set=foo=bar", "-topic=foo"}))
+
+	n := resetFlags(fs)
+
+	assert.Equal(t, "250", n.Lookup("window").Value.String())
+	assert.Equal(t, "", n.Lookup("set").Value.String())
+	assert.Equal(t, "", fs.Lookup("topic").Value.String())
+
+	assert.NoError(t, n.Parse([]string{"-topic=bar"}))
+	assert.Equal(t, "bar", fs.Lookup("topic").Value.String())
+	n.Visit(func(f *flag.Flag) {
+		assert.Equal(t, "topic", f.Name)
+	})
+}
+
+func TestApplySettings(t *testing.T) {
+	fs := testFlagSet()
+	assert.NoError(t, fs.Parse([]string{"-window=100"}))
+
+	file := map[string][]string{
+		"mode":   {"2"},
+		"window": {"500"},
+		"set":    {"source=replay", "version=2"},
+	}
+	env := map[string]string{
+		"PLAYBACK_MODE":       "1",
+		"PLAYBACK_PROJECT_ID": "my-project",
+	}
+	e := applySettings(fs, file, func(k string) (string, bool) {
+		v, found := env[k]
+		return v, found
+	})
+
+	assert.NoError(t, e)
+	assert.Equal(t, "2", fs.Lookup("mode").Value.String())
+	assert.Equal(t, "100", fs.Lookup("window").Value.String())
+	assert.Equal(t, "my-project", fs.Lookup("project_id").Value.String())
+	assert.Equal(t, "source=replay,version=2", fs.Lookup("set").Value.String())
+	assert.Equal(t, "", fs.Lookup("topic").Value.String())
+
+	e = applySettings(testFlagSet(), map[string][]string{"mode": {"foo"}}, lookupEnv)
+
+	assert.Error(t, e)
+}
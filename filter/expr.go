@@ -0,0 +1,425 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pburakov/playback/input"
+)
+
+// Expr is a parsed filter expression evaluated against decoded records.
+//
+// The expression language supports comparison operators (==, !=, <, <=, >, >=
+// and =~ for regular expression matching), logical operators (&&, || and !),
+// parentheses, string literals in double quotes, numbers, booleans, null and
+// dot-separated field paths, e.g.:
+//
+//	event_type == "purchase" && (amount > 100 || user.tier != "free")
+type Expr struct {
+	root node
+}
+
+// Parse compiles the filter expression.
+func Parse(s string) (*Expr, error) {
+	toks, e := tokenize(s)
+	if e != nil {
+		return nil, e
+	}
+	p := &parser{toks: toks}
+	n, e := p.parseOr()
+	if e != nil {
+		return nil, e
+	}
+	if p.pos != len(p.toks) {
+		return nil, fmt.Errorf("unexpected token %q in filter expression", p.toks[p.pos].val)
+	}
+	return &Expr{root: n}, nil
+}
+
+// Match evaluates the expression against the record. Missing fields evaluate
+// to null.
+func (x *Expr) Match(rec map[string]interface{}) (bool, error) {
+	v, e := x.root.eval(rec)
+	if e != nil {
+		return false, e
+	}
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return false, fmt.Errorf("filter expression is not a boolean: %v", v)
+}
+
+type node interface {
+	eval(rec map[string]interface{}) (interface{}, error)
+}
+
+type literal struct {
+	v interface{}
+}
+
+func (l *literal) eval(map[string]interface{}) (interface{}, error) {
+	return l.v, nil
+}
+
+type field struct {
+	path string
+}
+
+func (f *field) eval(rec map[string]interface{}) (interface{}, error) {
+	v, _ := input.Lookup(rec, f.path)
+	return v, nil
+}
+
+type not struct {
+	n node
+}
+
+func (n *not) eval(rec map[string]interface{}) (interface{}, error) {
+	v, e := n.n.eval(rec)
+	if e != nil {
+		return nil, e
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("operator ! expects a boolean, got %v", v)
+	}
+	return !b, nil
+}
+
+type logical struct {
+	op   string
+	l, r node
+}
+
+func (n *logical) eval(rec map[string]interface{}) (interface{}, error) {
+	l, e := evalBool(n.l, rec, n.op)
+	if e != nil {
+		return nil, e
+	}
+	// Short-circuit evaluation
+	if (n.op == "&&" && !l) || (n.op == "||" && l) {
+		return l, nil
+	}
+	return evalBool(n.r, rec, n.op)
+}
+
+func evalBool(n node, rec map[string]interface{}, op string) (bool, error) {
+	v, e := n.eval(rec)
+	if e != nil {
+		return false, e
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("operator %s expects booleans, got %v", op, v)
+	}
+	return b, nil
+}
+
+type comparison struct {
+	op   string
+	l, r node
+	re   *regexp.Regexp
+}
+
+func (n *comparison) eval(rec map[string]interface{}) (interface{}, error) {
+	l, e := n.l.eval(rec)
+	if e != nil {
+		return nil, e
+	}
+	if n.re != nil {
+		if l == nil {
+			return false, nil
+		}
+		return n.re.MatchString(fmt.Sprint(l)), nil
+	}
+	r, e := n.r.eval(rec)
+	if e != nil {
+		return nil, e
+	}
+	return compare(n.op, l, r)
+}
+
+// compare compares two values. Numbers are compared numerically, strings that
+// can be parsed as numbers are compared to numbers numerically (e.g. CSV
+// values), other values are compared as strings.
+func compare(op string, l, r interface{}) (bool, error) {
+	if l == nil || r == nil {
+		switch op {
+		case "==":
+			return l == nil && r == nil, nil
+		case "!=":
+			return !(l == nil && r == nil), nil
+		default:
+			return false, nil
+		}
+	}
+	if lb, ok := l.(bool); ok {
+		rb, ok := r.(bool)
+		switch {
+		case op == "==":
+			return ok && lb == rb, nil
+		case op == "!=":
+			return !ok || lb != rb, nil
+		default:
+			return false, fmt.Errorf("operator %s is not supported for booleans", op)
+		}
+	}
+	ln, lok := number(l)
+	rn, rok := number(r)
+	_, lstr := l.(string)
+	_, rstr := r.(string)
+	if lok && rok && !(lstr && rstr) {
+		return compareOrdered(op, ln < rn, ln == rn), nil
+	}
+	ls, rs := fmt.Sprint(l), fmt.Sprint(r)
+	return compareOrdered(op, ls < rs, ls == rs), nil
+}
+
+func compareOrdered(op string, less bool, equal bool) bool {
+	switch op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	default: // ">="
+		return !less
+	}
+}
+
+// number converts a numeric or numeric string value to float.
+func number(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case float32:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case int32:
+		return float64(t), true
+	case int:
+		return float64(t), true
+	case json.Number:
+		f, e := t.Float64()
+		return f, e == nil
+	case string:
+		f, e := strconv.ParseFloat(t, 64)
+		return f, e == nil
+	default:
+		return 0, false
+	}
+}
+
+type token struct {
+	kind rune // 's' - string, 'n' - number, 'i' - identifier, 'o' - operator
+	val  string
+}
+
+// tokenize splits the expression into tokens.
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(rs) && rs[j] != '"'; j++ {
+				if rs[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated string in filter expression")
+			}
+			v, e := strconv.Unquote(string(rs[i : j+1]))
+			if e != nil {
+				return nil, fmt.Errorf("invalid string %s in filter expression", string(rs[i:j+1]))
+			}
+			toks = append(toks, token{'s', v})
+			i = j + 1
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.' || rs[j] == 'e' || rs[j] == 'E') {
+				j++
+			}
+			toks = append(toks, token{'n', string(rs[i:j])})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i + 1
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{'i', string(rs[i:j])})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"==", "!=", "<=", ">=", "=~", "&&", "||", "<", ">", "!", "(", ")"} {
+				if strings.HasPrefix(string(rs[i:]), o) {
+					op = o
+					break
+				}
+			}
+			if len(op) == 0 {
+				return nil, fmt.Errorf("unexpected character %q in filter expression", c)
+			}
+			toks = append(toks, token{'o', op})
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+// parser is a recursive descent parser of the filter expression grammar:
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | comparison
+//	comparison = primary [ op primary ]
+//	primary    = "(" or ")" | string | number | "true" | "false" | "null" | path
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.toks) {
+		return &p.toks[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t != nil && t.kind == 'o' && t.val == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, e := p.parseAnd()
+	if e != nil {
+		return nil, e
+	}
+	for p.accept("||") {
+		r, e := p.parseAnd()
+		if e != nil {
+			return nil, e
+		}
+		l = &logical{op: "||", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, e := p.parseUnary()
+	if e != nil {
+		return nil, e
+	}
+	for p.accept("&&") {
+		r, e := p.parseUnary()
+		if e != nil {
+			return nil, e
+		}
+		l = &logical{op: "&&", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		n, e := p.parseUnary()
+		if e != nil {
+			return nil, e
+		}
+		return &not{n: n}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	l, e := p.parsePrimary()
+	if e != nil {
+		return nil, e
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if !p.accept(op) {
+			continue
+		}
+		r, e := p.parsePrimary()
+		if e != nil {
+			return nil, e
+		}
+		c := &comparison{op: op, l: l, r: r}
+		if op == "=~" {
+			var s string
+			if lit, ok := r.(*literal); ok {
+				s, ok = lit.v.(string)
+				if !ok {
+					return nil, fmt.Errorf("operator =~ expects a string pattern")
+				}
+			} else {
+				return nil, fmt.Errorf("operator =~ expects a string pattern")
+			}
+			if c.re, e = regexp.Compile(s); e != nil {
+				return nil, e
+			}
+		}
+		return c, nil
+	}
+	return l, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of filter expression")
+	}
+	p.pos++
+	switch t.kind {
+	case 's':
+		return &literal{v: t.val}, nil
+	case 'n':
+		f, e := strconv.ParseFloat(t.val, 64)
+		if e != nil {
+			return nil, fmt.Errorf("invalid number %q in filter expression", t.val)
+		}
+		return &literal{v: f}, nil
+	case 'i':
+		switch t.val {
+		case "true":
+			return &literal{v: true}, nil
+		case "false":
+			return &literal{v: false}, nil
+		case "null":
+			return &literal{v: nil}, nil
+		default:
+			return &field{path: t.val}, nil
+		}
+	default:
+		if t.val == "(" {
+			n, e := p.parseOr()
+			if e != nil {
+				return nil, e
+			}
+			if !p.accept(")") {
+				return nil, fmt.Errorf("missing closing parenthesis in filter expression")
+			}
+			return n, nil
+		}
+		return nil, fmt.Errorf("unexpected token %q in filter expression", t.val)
+	}
+}
@@ -0,0 +1,75 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/pburakov/playback/input"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPayload = `{"event_type":"purchase","amount":142.5,"count":"3","user":{"id":"u1","tier":"free","active":true},"note":null}`
+
+func TestMatch(t *testing.T) {
+	rec, _ := input.JSONCodec{}.Decode([]byte(testPayload))
+
+	for x, expected := range map[string]bool{
+		`event_type == "purchase" && amount > 100`:    true,
+		`event_type == "purchase" && amount > 200`:    false,
+		`event_type != "purchase" || amount >= 142.5`: true,
+		`!(user.tier == "free")`:                      false,
+		`user.active`:                                 true,
+		`user.active == false`:                        false,
+		`count < 10`:                                  true,
+		`count == "3"`:                                true,
+		`note == null && missing == null`:             true,
+		`user.id =~ "^u[0-9]+$"`:                      true,
+		`missing =~ ".*"`:                             false,
+		`amount <= -1 || (user.id == "u2" && true)`:   false,
+		`event_type > "click" && event_type < "zzz"`:  true,
+	} {
+		ok, e := mustParse(t, x).Match(rec)
+
+		assert.NoError(t, e, x)
+		assert.Equal(t, expected, ok, x)
+	}
+
+	// Non-boolean operands of logical operators
+	_, e := mustParse(t, `user.tier == "free" && amount`).Match(rec)
+
+	assert.Error(t, e)
+}
+
+func TestMatchAvroUnion(t *testing.T) {
+	rec := map[string]interface{}{
+		"foo": map[string]interface{}{"long": int64(1)},
+		"faz": map[string]interface{}{"root.Faz": map[string]interface{}{
+			"A": map[string]interface{}{"string": "foo"},
+		}},
+	}
+
+	ok, e := mustParse(t, `foo == 1 && faz.A == "foo"`).Match(rec)
+
+	assert.NoError(t, e)
+	assert.True(t, ok)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, x := range []string{
+		`event_type ==`,
+		`(amount > 1`,
+		`amount > 1)`,
+		`"unterminated`,
+		`amount # 1`,
+		`user.id =~ user.name`,
+		`user.id =~ "["`,
+	} {
+		_, e := Parse(x)
+		assert.Error(t, e, x)
+	}
+}
+
+func mustParse(t *testing.T, s string) *Expr {
+	x, e := Parse(s)
+	assert.NoError(t, e, s)
+	return x
+}
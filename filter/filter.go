@@ -0,0 +1,106 @@
+package filter
+
+import (
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/pburakov/playback/clock"
+	"github.com/pburakov/playback/input"
+)
+
+// Reader skips the records of the underlying reader that don't match the
+// filter expression. Records are decoded using the codec of the input format.
+// Records the expression can't be evaluated on, e.g. with a missing boolean
+// field, are skipped as well and counted separately.
+type Reader struct {
+	r        input.FileReader
+	c        input.Codec
+	x        *Expr
+	filtered uint64
+	errors   uint64
+}
+
+var _ input.FileReader = (*Reader)(nil)
+var _ input.CodecProvider = (*Reader)(nil)
+var _ input.Clocked = (*Reader)(nil)
+
+// Init returns a filtering reader wrapping the given reader. The reader must
+// provide a codec for its data.
+func Init(r input.FileReader, x *Expr) (*Reader, error) {
+	p, ok := r.(input.CodecProvider)
+	if !ok || p.Codec() == nil {
+		return nil, errors.New("filtering is not supported by the reader")
+	}
+	return &Reader{r: r, c: p.Codec(), x: x}, nil
+}
+
+func (f *Reader) ReadLineWithTS() (ts time.Time, data []byte, e error) {
+	for {
+		ts, data, e = f.r.ReadLineWithTS()
+		if e != nil {
+			return ts, nil, e
+		}
+		if ok, e := f.match(data); e != nil || ok {
+			return ts, data, e
+		}
+	}
+}
+
+func (f *Reader) ReadLine() (data []byte, e error) {
+	for {
+		data, e = f.r.ReadLine()
+		if e != nil {
+			return nil, e
+		}
+		if ok, e := f.match(data); e != nil || ok {
+			return data, e
+		}
+	}
+}
+
+// Close closes the underlying reader.
+func (f *Reader) Close() error {
+	return input.Close(f.r)
+}
+
+func (f *Reader) Codec() input.Codec {
+	return f.c
+}
+
+// SetClock sets the clock of the underlying reader.
+func (f *Reader) SetClock(c clock.Clock) {
+	input.SetClock(f.r, c)
+}
+
+// Filtered returns the number of records skipped so far.
+func (f *Reader) Filtered() uint64 {
+	return atomic.LoadUint64(&f.filtered)
+}
+
+// Errors returns the number of records skipped so far because the filter
+// expression failed to evaluate.
+func (f *Reader) Errors() uint64 {
+	return atomic.LoadUint64(&f.errors)
+}
+
+// match decodes the data and evaluates the filter expression. Evaluation
+// errors are treated as a mismatch, and only the first one is logged.
+func (f *Reader) match(d []byte) (bool, error) {
+	rec, e := f.c.Decode(d)
+	if e != nil {
+		return false, e
+	}
+	ok, e := f.x.Match(rec)
+	if e != nil {
+		if atomic.AddUint64(&f.errors, 1) == 1 {
+			log.Printf("Failed to evaluate the filter expression, skipping the record: %s", e)
+		}
+		return false, nil
+	}
+	if !ok {
+		atomic.AddUint64(&f.filtered, 1)
+	}
+	return ok, nil
+}
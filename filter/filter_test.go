@@ -0,0 +1,46 @@
+package filter
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pburakov/playback/input/csv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReader(t *testing.T) {
+	in, _ := csv.Init("../input/csv/input_test.csv", "baz", "2006-01-02 15:04:05 UTC")
+	r, e := Init(in, mustParse(t, `bar > 3`))
+
+	assert.NoError(t, e)
+
+	_, l, e := r.ReadLineWithTS()
+
+	assert.NoError(t, e)
+	assert.Equal(t, `{"bar":"4","baz":"2019-02-07 12:53:31 UTC","foo":"3"}`, string(l))
+	assert.Equal(t, uint64(1), r.Filtered())
+
+	_, e = r.ReadLine()
+
+	assert.Equal(t, io.EOF, e)
+}
+
+func TestReaderEvalErrors(t *testing.T) {
+	in, _ := csv.Init("../input/csv/input_test.csv", "baz", "2006-01-02 15:04:05 UTC")
+	r, e := Init(in, mustParse(t, `!missing || foo == "3"`))
+
+	assert.NoError(t, e)
+
+	_, e = r.ReadLine()
+
+	assert.Equal(t, io.EOF, e)
+	assert.Equal(t, uint64(2), r.Errors())
+	assert.Equal(t, uint64(0), r.Filtered())
+}
+
+func TestInitErrors(t *testing.T) {
+	r, e := Init(nil, mustParse(t, "true"))
+
+	assert.Error(t, e)
+	assert.Nil(t, r)
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/linkedin/goavro"
@@ -18,11 +19,13 @@ type properties struct {
 }
 
 type AvroReader struct {
+	f *os.File
 	r *goavro.OCFReader
 	p *properties
 }
 
 var _ input.FileReader = (*AvroReader)(nil)
+var _ input.CodecProvider = (*AvroReader)(nil)
 
 func Init(path string, colName string, tsFormat string) (*AvroReader, error) {
 	f, e := os.Open(path)
@@ -32,12 +35,13 @@ func Init(path string, colName string, tsFormat string) (*AvroReader, error) {
 
 	r, e := goavro.NewOCFReader(f)
 	if e != nil {
+		f.Close()
 		return nil, e
 	}
 
 	log.Printf("Loading avro file %q (compression algorithm %q)", path, r.CompressionName())
 
-	return &AvroReader{r: r, p: &properties{tsColumn: colName, tsFormat: tsFormat}}, nil
+	return &AvroReader{f: f, r: r, p: &properties{tsColumn: colName, tsFormat: tsFormat}}, nil
 }
 
 func (a *AvroReader) ReadLineWithTS() (ts time.Time, data []byte, e error) {
@@ -98,3 +102,69 @@ func extractTimestamp(m map[string]interface{}, col string, format string) (time
 		}
 	}
 }
+
+// Close closes the input file.
+func (a *AvroReader) Close() error {
+	return a.f.Close()
+}
+
+// Codec returns a codec for the binary Avro records produced by the reader,
+// using the writer schema of the input file.
+func (a *AvroReader) Codec() input.Codec {
+	return &Codec{c: a.r.Codec(), schema: parseSchema(a.r.Codec().Schema())}
+}
+
+// Codec converts binary Avro records to native Go values and back. Missing
+// nullable top-level fields of the record are encoded as nulls.
+type Codec struct {
+	c      *goavro.Codec
+	schema *recordSchema
+}
+
+var _ input.SchemaCodec = (*Codec)(nil)
+
+func (c *Codec) Decode(data []byte) (map[string]interface{}, error) {
+	n, _, e := c.c.NativeFromBinary(data)
+	if e != nil {
+		return nil, e
+	}
+	if rec, ok := n.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("unable to parse record")
+	} else {
+		return rec, nil
+	}
+}
+
+func (c *Codec) Encode(rec map[string]interface{}) ([]byte, error) {
+	for _, n := range c.schema.names {
+		if _, found := rec[n]; !found && c.schema.fields[n].nullable() {
+			rec[n] = nil
+		}
+	}
+	return c.c.BinaryFromNative(nil, rec)
+}
+
+// Field returns the schema of the field at the dot-separated path. Fields with
+// default values and nullable top-level fields are optional.
+func (c *Codec) Field(path string) (input.FieldSchema, bool) {
+	if len(path) == 0 {
+		return input.FieldSchema{Fields: c.schema.names}, true
+	}
+	segs := strings.Split(path, ".")
+	rec := c.schema
+	var f *fieldSchema
+	for _, s := range segs {
+		if rec == nil {
+			return input.FieldSchema{}, false
+		}
+		if f = rec.fields[s]; f == nil {
+			return input.FieldSchema{}, false
+		}
+		rec = f.record
+	}
+	fs := input.FieldSchema{Types: f.types, Optional: f.dflt || len(segs) == 1 && f.nullable()}
+	if f.record != nil {
+		fs.Fields = f.record.names
+	}
+	return fs, true
+}
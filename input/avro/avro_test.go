@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pburakov/playback/input"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -75,3 +76,45 @@ func TestTSErrors(t *testing.T) {
 
 	assert.Error(t, e)
 }
+
+func TestClose(t *testing.T) {
+	r, _ := Init(testFile, testColumn, testDateTimeFormat)
+
+	assert.NoError(t, r.Close())
+	// the file is already closed
+	assert.Error(t, r.Close())
+}
+
+func TestCodecField(t *testing.T) {
+	r, _ := Init(testFile, testColumn, testDateTimeFormat)
+	defer r.Close()
+	c := r.Codec().(*Codec)
+
+	f, found := c.Field("")
+	assert.True(t, found)
+	assert.Equal(t, []string{"foo", "bar", "baz", "faz"}, f.Fields)
+
+	f, found = c.Field("foo")
+	assert.True(t, found)
+	assert.Equal(t, input.FieldSchema{Types: []string{"null", "long"}, Optional: true}, f)
+
+	f, found = c.Field("baz")
+	assert.True(t, found)
+	assert.Equal(t, input.FieldSchema{Types: []string{"array"}}, f)
+
+	f, found = c.Field("faz")
+	assert.True(t, found)
+	assert.Equal(t, []string{"null", "root.Faz"}, f.Types)
+	assert.Equal(t, []string{"A", "B"}, f.Fields)
+
+	// nested nullable fields without defaults can't be left out
+	f, found = c.Field("faz.B")
+	assert.True(t, found)
+	assert.Equal(t, input.FieldSchema{Types: []string{"null", "double"}}, f)
+	assert.True(t, f.Nullable())
+
+	_, found = c.Field("faz.C")
+	assert.False(t, found)
+	_, found = c.Field("foo.A")
+	assert.False(t, found)
+}
@@ -0,0 +1,124 @@
+package avro
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// recordSchema is a parsed record schema, holding the fields in order.
+type recordSchema struct {
+	names  []string
+	fields map[string]*fieldSchema
+}
+
+// fieldSchema is a parsed record field schema.
+type fieldSchema struct {
+	// type names, more than one for unions
+	types []string
+	// the record type of the field, or of one of the union branches
+	record *recordSchema
+	// true if the field has a default value
+	dflt bool
+}
+
+func (f *fieldSchema) nullable() bool {
+	for _, t := range f.types {
+		if t == "null" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSchema parses the schema of the top-level record. An empty record is
+// returned if the schema can't be parsed.
+func parseSchema(schema string) *recordSchema {
+	var v interface{}
+	if e := json.Unmarshal([]byte(schema), &v); e == nil {
+		if _, rec := parseType(v, "", make(map[string]*recordSchema)); rec != nil {
+			return rec
+		}
+	}
+	return &recordSchema{fields: make(map[string]*fieldSchema)}
+}
+
+// parseType returns the name of the type defined by the schema within the
+// given namespace, along with the record schema if the type is a record.
+// Parsed records are registered by their full names, so that they can be
+// referenced later in the schema.
+func parseType(v interface{}, ns string, named map[string]*recordSchema) (string, *recordSchema) {
+	switch t := v.(type) {
+	case string:
+		if isPrimitive(t) {
+			return t, nil
+		}
+		n := fullName(t, ns)
+		return n, named[n]
+	case map[string]interface{}:
+		kind, _ := t["type"].(string)
+		switch kind {
+		case "record", "error", "enum", "fixed":
+		default:
+			// array, map or a primitive with attributes, e.g. a logical type
+			return kind, nil
+		}
+		name, _ := t["name"].(string)
+		if n, ok := t["namespace"].(string); ok {
+			ns = n
+		}
+		n := fullName(name, ns)
+		if i := strings.LastIndex(n, "."); i >= 0 {
+			ns = n[:i]
+		}
+		if kind != "record" && kind != "error" {
+			return n, nil
+		}
+
+		rec := &recordSchema{fields: make(map[string]*fieldSchema)}
+		named[n] = rec
+		fields, _ := t["fields"].([]interface{})
+		for _, f := range fields {
+			m, _ := f.(map[string]interface{})
+			fn, _ := m["name"].(string)
+			_, dflt := m["default"]
+			fs := &fieldSchema{dflt: dflt}
+			for _, b := range branches(m["type"]) {
+				bn, br := parseType(b, ns, named)
+				fs.types = append(fs.types, bn)
+				if br != nil {
+					fs.record = br
+				}
+			}
+			rec.names = append(rec.names, fn)
+			rec.fields[fn] = fs
+		}
+		return n, rec
+	default:
+		return "", nil
+	}
+}
+
+// branches returns the branches of a union type, or the type itself.
+func branches(v interface{}) []interface{} {
+	if u, ok := v.([]interface{}); ok {
+		return u
+	}
+	return []interface{}{v}
+}
+
+// fullName returns the name qualified with the namespace, unless it's already
+// qualified.
+func fullName(name string, ns string) string {
+	if len(ns) == 0 || strings.Contains(name, ".") {
+		return name
+	}
+	return ns + "." + name
+}
+
+func isPrimitive(t string) bool {
+	switch t {
+	case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package input
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Codec converts the binary data produced by a FileReader into a generic
+// key-value record and back.
+type Codec interface {
+	// Decode deserializes binary data into a record.
+	Decode(data []byte) (map[string]interface{}, error)
+
+	// Encode serializes the record back into binary data.
+	Encode(rec map[string]interface{}) ([]byte, error)
+}
+
+// CodecProvider is implemented by readers that can decode their own output.
+type CodecProvider interface {
+	// Codec returns a codec for the binary data returned by the reader.
+	Codec() Codec
+}
+
+// SchemaCodec is implemented by codecs bound to a record schema, which can't
+// encode fields missing from the schema.
+type SchemaCodec interface {
+	Codec
+
+	// Field returns the schema of the field at the dot-separated path, or of
+	// the root record for an empty path. False is returned if the field isn't
+	// defined in the schema.
+	Field(path string) (FieldSchema, bool)
+}
+
+// FieldSchema describes a record field defined in a schema.
+type FieldSchema struct {
+	// Types lists the type names of the field value, more than one for
+	// unions, e.g. "null" and "string". Named types are listed by their full
+	// names.
+	Types []string
+	// Fields lists the names of the nested record fields, if the field is a
+	// record or a union including one.
+	Fields []string
+	// Optional is true if the field can be left out of the record.
+	Optional bool
+}
+
+// Nullable returns true if the field value can be null.
+func (f FieldSchema) Nullable() bool {
+	for _, t := range f.Types {
+		if t == "null" {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONCodec handles JSON objects. It is shared by JSON and CSV readers, since
+// CSV rows are converted to JSON objects on read. Numbers are kept as
+// json.Number values to avoid losing precision on a round trip.
+type JSONCodec struct{}
+
+var _ Codec = JSONCodec{}
+
+func (JSONCodec) Decode(data []byte) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if e := d.Decode(&m); e != nil {
+		return nil, e
+	}
+	return m, nil
+}
+
+// Encode serializes the record as a compact JSON object. Newline delimiters of
+// the source file are not preserved.
+func (JSONCodec) Encode(rec map[string]interface{}) ([]byte, error) {
+	return json.Marshal(rec)
+}
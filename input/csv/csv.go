@@ -20,11 +20,13 @@ type properties struct {
 }
 
 type CSVReader struct {
+	f *os.File
 	r *csv.Reader
 	p *properties
 }
 
 var _ input.FileReader = (*CSVReader)(nil)
+var _ input.CodecProvider = (*CSVReader)(nil)
 
 func Init(path string, colName string, tsFormat string) (*CSVReader, error) {
 	f, e := os.Open(path)
@@ -38,10 +40,11 @@ func Init(path string, colName string, tsFormat string) (*CSVReader, error) {
 
 	line, e := r.Read()
 	if e != nil {
+		f.Close()
 		return nil, e
 	}
 
-	return &CSVReader{r: r, p: &properties{headers: line, tsColumn: colName, tsFormat: tsFormat}}, nil
+	return &CSVReader{f: f, r: r, p: &properties{headers: line, tsColumn: colName, tsFormat: tsFormat}}, nil
 }
 
 // ReadLine returns CSV entry as a serialized JSON k-v object.
@@ -102,3 +105,13 @@ func extractTimestamp(row map[string]string, col string, format string) (time.Ti
 		return ts, nil
 	}
 }
+
+// Close closes the input file.
+func (c *CSVReader) Close() error {
+	return c.f.Close()
+}
+
+// Codec returns a codec for CSV records, which are serialized as JSON objects.
+func (c *CSVReader) Codec() input.Codec {
+	return input.JSONCodec{}
+}
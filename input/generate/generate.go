@@ -0,0 +1,353 @@
+package generate
+
+import (
+	"bytes"
+	encsv "encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pburakov/playback/clock"
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/util"
+)
+
+type properties struct {
+	tsFormat string
+	count    uint64
+}
+
+// Generator produces records from a JSON template instead of reading a file.
+// String values of the template may contain placeholders in double curly
+// braces, which are replaced with generated values on every read:
+//
+//	{{seq [START]}}        sequence number, starting from 1 or START
+//	{{uuid}}               random UUID
+//	{{int MIN MAX}}        random integer within [MIN, MAX]
+//	{{float MIN MAX}}      random float within [MIN, MAX)
+//	{{string LEN}}         random alphanumeric string of length LEN
+//	{{enum A B ...}}       random choice of the listed values
+//	{{now}}                current time of the clock, formatted with the timestamp format
+//	{{csv PATH COLUMN}}    random value of the CSV file column
+//
+// A string consisting of a single placeholder is replaced with a value of the
+// generated type (e.g. a number), otherwise placeholders are substituted as text.
+type Generator struct {
+	tmpl  interface{}
+	p     *properties
+	rand  *rand.Rand
+	clock clock.Clock
+	seq   uint64
+}
+
+var _ input.FileReader = (*Generator)(nil)
+var _ input.CodecProvider = (*Generator)(nil)
+var _ input.Clocked = (*Generator)(nil)
+
+var placeholder = regexp.MustCompile(`\{\{\s*(\w+)((?:\s+[^\s}]+)*)\s*\}\}`)
+
+// Init loads the JSON template and returns a generator producing the given
+// number of records, or an unlimited number of records if count is 0. Random
+// values are drawn from the given source, so that a seeded source produces
+// the same records.
+func Init(path string, tsFormat string, count uint64, r *rand.Rand) (*Generator, error) {
+	b, e := ioutil.ReadFile(path)
+	if e != nil {
+		return nil, e
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var raw interface{}
+	if e := d.Decode(&raw); e != nil {
+		return nil, fmt.Errorf("invalid template %q: %s", path, e)
+	}
+
+	log.Printf("Loading template file %q", path)
+
+	g := &Generator{p: &properties{tsFormat: tsFormat, count: count}, rand: r, clock: clock.Real}
+	if g.tmpl, e = g.compile(raw); e != nil {
+		return nil, e
+	}
+	return g, nil
+}
+
+// SetClock sets the clock providing the generation time, e.g. the clock of
+// the player. Wall clock is used by default.
+func (g *Generator) SetClock(c clock.Clock) {
+	g.clock = c
+}
+
+// ReadLineWithTS generates the next record. Generation time is returned as
+// the record timestamp.
+func (g *Generator) ReadLineWithTS() (ts time.Time, data []byte, e error) {
+	ts = g.clock.Now()
+	data, e = g.generate(ts)
+	if e != nil {
+		return util.DefaultTimestamp(), nil, e
+	}
+	return ts, data, nil
+}
+
+func (g *Generator) ReadLine() (data []byte, e error) {
+	return g.generate(g.clock.Now())
+}
+
+// Codec returns a codec for the generated JSON objects.
+func (g *Generator) Codec() input.Codec {
+	return input.JSONCodec{}
+}
+
+func (g *Generator) generate(now time.Time) ([]byte, error) {
+	if g.p.count != 0 && g.seq >= g.p.count {
+		return nil, io.EOF
+	}
+	g.seq++
+	return json.Marshal(g.eval(g.tmpl, now))
+}
+
+// value generates a single placeholder value.
+type value func(seq uint64, now time.Time) interface{}
+
+// text is a string with placeholders, consisting of alternating literal parts
+// and generated values.
+type text struct {
+	parts  []string
+	values []value
+}
+
+// object is a template object with the fields sorted by name, so that random
+// values are drawn in the same order for every record.
+type object struct {
+	keys   []string
+	values []interface{}
+}
+
+// compile replaces placeholder strings of the template with generators.
+func (g *Generator) compile(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		o := &object{keys: make([]string, 0, len(t))}
+		for k := range t {
+			o.keys = append(o.keys, k)
+		}
+		sort.Strings(o.keys)
+		for _, k := range o.keys {
+			cv, e := g.compile(t[k])
+			if e != nil {
+				return nil, e
+			}
+			o.values = append(o.values, cv)
+		}
+		return o, nil
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, c := range t {
+			cv, e := g.compile(c)
+			if e != nil {
+				return nil, e
+			}
+			l[i] = cv
+		}
+		return l, nil
+	case string:
+		idx := placeholder.FindAllStringSubmatchIndex(t, -1)
+		if len(idx) == 0 {
+			return t, nil
+		}
+		txt := &text{}
+		last := 0
+		for _, m := range idx {
+			f, e := compilePlaceholder(t[m[2]:m[3]], strings.Fields(t[m[4]:m[5]]), g.p.tsFormat, g.rand)
+			if e != nil {
+				return nil, fmt.Errorf("invalid placeholder %q: %s", t[m[0]:m[1]], e)
+			}
+			txt.parts = append(txt.parts, t[last:m[0]])
+			txt.values = append(txt.values, f)
+			last = m[1]
+		}
+		txt.parts = append(txt.parts, t[last:])
+		if len(txt.values) == 1 && len(txt.parts[0]) == 0 && len(txt.parts[1]) == 0 {
+			return txt.values[0], nil
+		}
+		return txt, nil
+	default:
+		return v, nil
+	}
+}
+
+// eval generates a record from the compiled template.
+func (g *Generator) eval(v interface{}, now time.Time) interface{} {
+	switch t := v.(type) {
+	case *object:
+		m := make(map[string]interface{}, len(t.keys))
+		for i, k := range t.keys {
+			m[k] = g.eval(t.values[i], now)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, c := range t {
+			l[i] = g.eval(c, now)
+		}
+		return l
+	case value:
+		return t(g.seq, now)
+	case *text:
+		var b strings.Builder
+		for i, p := range t.parts {
+			b.WriteString(p)
+			if i < len(t.values) {
+				fmt.Fprint(&b, t.values[i](g.seq, now))
+			}
+		}
+		return b.String()
+	default:
+		return v
+	}
+}
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// compilePlaceholder returns a value generator for the placeholder function
+// and its arguments, drawing random values from the given source.
+func compilePlaceholder(fn string, args []string, tsFormat string, r *rand.Rand) (value, error) {
+	switch fn {
+	case "seq":
+		start := int64(1)
+		if len(args) > 0 {
+			var e error
+			if start, e = strconv.ParseInt(args[0], 10, 64); e != nil {
+				return nil, e
+			}
+		}
+		return func(seq uint64, _ time.Time) interface{} {
+			return start + int64(seq) - 1
+		}, nil
+	case "uuid":
+		return func(uint64, time.Time) interface{} {
+			return util.UUID(r)
+		}, nil
+	case "int":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected min and max arguments")
+		}
+		min, e1 := strconv.ParseInt(args[0], 10, 64)
+		max, e2 := strconv.ParseInt(args[1], 10, 64)
+		if e1 != nil || e2 != nil || max < min {
+			return nil, fmt.Errorf("invalid range")
+		}
+		// Width of the range is computed in unsigned arithmetic, since it
+		// overflows int64 for ranges wider than half of the int64 values
+		w := uint64(max-min) + 1
+		return func(uint64, time.Time) interface{} {
+			return min + int64(randUint64n(r, w))
+		}, nil
+	case "float":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected min and max arguments")
+		}
+		min, e1 := strconv.ParseFloat(args[0], 64)
+		max, e2 := strconv.ParseFloat(args[1], 64)
+		if e1 != nil || e2 != nil || max < min {
+			return nil, fmt.Errorf("invalid range")
+		}
+		return func(uint64, time.Time) interface{} {
+			return min + r.Float64()*(max-min)
+		}, nil
+	case "string":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected length argument")
+		}
+		n, e := strconv.Atoi(args[0])
+		if e != nil || n < 0 {
+			return nil, fmt.Errorf("invalid length")
+		}
+		return func(uint64, time.Time) interface{} {
+			b := make([]byte, n)
+			for i := range b {
+				b[i] = alphanumeric[r.Intn(len(alphanumeric))]
+			}
+			return string(b)
+		}, nil
+	case "enum":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("expected at least one value")
+		}
+		return choice(r, args), nil
+	case "now":
+		return func(_ uint64, now time.Time) interface{} {
+			return now.Format(tsFormat)
+		}, nil
+	case "csv":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected path and column arguments")
+		}
+		vals, e := loadColumn(args[0], args[1])
+		if e != nil {
+			return nil, e
+		}
+		return choice(r, vals), nil
+	default:
+		return nil, fmt.Errorf("unknown function %q", fn)
+	}
+}
+
+func choice(r *rand.Rand, vals []string) value {
+	return func(uint64, time.Time) interface{} {
+		return vals[r.Intn(len(vals))]
+	}
+}
+
+// loadColumn reads all values of the CSV file column.
+func loadColumn(path string, col string) ([]string, error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+
+	rows, e := encsv.NewReader(f).ReadAll()
+	if e != nil {
+		return nil, e
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("no values in csv file %q", path)
+	}
+	for i, h := range rows[0] {
+		if h == col {
+			vals := make([]string, 0, len(rows)-1)
+			for _, r := range rows[1:] {
+				vals = append(vals, r[i])
+			}
+			return vals, nil
+		}
+	}
+	return nil, fmt.Errorf("column %q not found in csv file %q", col, path)
+}
+
+// randUint64n returns a random number within [0, n), or any uint64 number if n
+// is 0, i.e. the range covers all of them.
+func randUint64n(r *rand.Rand, n uint64) uint64 {
+	if n == 0 {
+		return r.Uint64()
+	}
+	if n <= math.MaxInt64 {
+		return uint64(r.Int63n(int64(n)))
+	}
+	// Rejection sampling accepts at least half of the drawn numbers
+	for {
+		if v := r.Uint64(); v < n {
+			return v
+		}
+	}
+}
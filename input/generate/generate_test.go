@@ -0,0 +1,149 @@
+package generate
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pburakov/playback/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testFile     = "template_test.json"
+	testTSFormat = "2006-01-02T15:04:05.999999Z07:00"
+)
+
+func testRand() *rand.Rand {
+	return rand.New(rand.NewSource(1))
+}
+
+func TestInitAndReadLines(t *testing.T) {
+	r, e := Init(testFile, testTSFormat, 2, testRand())
+
+	assert.NoError(t, e)
+	assert.Equal(t, &properties{tsFormat: testTSFormat, count: 2}, r.p)
+
+	before := time.Now()
+	ts, l, e := r.ReadLineWithTS()
+
+	assert.NoError(t, e)
+	assert.False(t, ts.Before(before))
+
+	rec := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(l, &rec))
+
+	assert.Equal(t, 100.0, rec["id"])
+	assert.Len(t, rec["key"], 36)
+	assert.True(t, rec["amount"].(float64) >= 1 && rec["amount"].(float64) <= 10)
+	assert.True(t, rec["score"].(float64) >= 0 && rec["score"].(float64) < 1)
+	assert.Regexp(t, `^user-[a-zA-Z0-9]{6}$`, rec["name"])
+	assert.Contains(t, []interface{}{"click", "view", "purchase"}, rec["type"])
+	assert.Equal(t, ts.Format(testTSFormat), rec["ts"])
+	assert.Contains(t, []interface{}{"1", "3"}, rec["tags"].([]interface{})[0])
+	assert.Equal(t, "static", rec["tags"].([]interface{})[1])
+	assert.Equal(t, map[string]interface{}{"label": "1/a", "const": 42.0}, rec["nested"])
+
+	l, e = r.ReadLine()
+
+	assert.NoError(t, e)
+	assert.NoError(t, json.Unmarshal(l, &rec))
+	assert.Equal(t, 101.0, rec["id"])
+
+	_, e = r.ReadLine()
+
+	assert.Equal(t, io.EOF, e)
+}
+
+func TestUnlimited(t *testing.T) {
+	r, _ := Init(testFile, testTSFormat, 0, testRand())
+
+	for i := 0; i < 1000; i++ {
+		_, e := r.ReadLine()
+		assert.NoError(t, e)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	r, e := Init("non_existent_file", testTSFormat, 0, testRand())
+
+	assert.Error(t, e)
+	assert.Nil(t, r)
+
+	for _, tmpl := range []string{
+		`not json`,
+		`{"foo": "{{bar}}"}`,
+		`{"foo": "{{int 10 1}}"}`,
+		`{"foo": "{{string}}"}`,
+		`{"foo": "{{enum}}"}`,
+		`{"foo": "{{csv ../csv/input_test.csv non_existent_column}}"}`,
+	} {
+		f, _ := ioutil.TempFile("", "template")
+		_, _ = f.WriteString(tmpl)
+		_ = f.Close()
+
+		_, e = Init(f.Name(), testTSFormat, 0, testRand())
+		assert.Error(t, e, tmpl)
+
+		_ = os.Remove(f.Name())
+	}
+}
+
+func TestSeed(t *testing.T) {
+	r1, _ := Init(testFile, testTSFormat, 0, testRand())
+	r2, _ := Init(testFile, testTSFormat, 0, testRand())
+
+	for i := 0; i < 10; i++ {
+		var rec1, rec2 map[string]interface{}
+		l, _ := r1.ReadLine()
+		assert.NoError(t, json.Unmarshal(l, &rec1))
+		l, _ = r2.ReadLine()
+		assert.NoError(t, json.Unmarshal(l, &rec2))
+
+		// the same seed produces the same values, except for the current time
+		delete(rec1, "ts")
+		delete(rec2, "ts")
+		assert.Equal(t, rec1, rec2)
+	}
+}
+
+func TestClock(t *testing.T) {
+	r, _ := Init(testFile, testTSFormat, 0, testRand())
+	now := time.Date(2019, 2, 4, 21, 16, 19, 0, time.UTC)
+	r.SetClock(clock.NewFake(now))
+
+	ts, l, e := r.ReadLineWithTS()
+
+	assert.NoError(t, e)
+	assert.Equal(t, now, ts)
+
+	rec := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(l, &rec))
+	assert.Equal(t, now.Format(testTSFormat), rec["ts"])
+}
+
+func TestWideIntRange(t *testing.T) {
+	for _, tmpl := range []string{
+		`{"foo": "{{int -9223372036854775808 9223372036854775807}}"}`,
+		`{"foo": "{{int -9223372036854775808 1}}"}`,
+		`{"foo": "{{int 9223372036854775807 9223372036854775807}}"}`,
+	} {
+		f, _ := ioutil.TempFile("", "template")
+		_, _ = f.WriteString(tmpl)
+		_ = f.Close()
+
+		r, e := Init(f.Name(), testTSFormat, 0, testRand())
+		assert.NoError(t, e, tmpl)
+
+		for i := 0; i < 10; i++ {
+			_, e = r.ReadLine()
+			assert.NoError(t, e, tmpl)
+		}
+
+		_ = os.Remove(f.Name())
+	}
+}
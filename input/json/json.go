@@ -17,11 +17,13 @@ type properties struct {
 }
 
 type JSONReader struct {
+	f *os.File
 	r *bufio.Reader
 	p *properties
 }
 
 var _ input.FileReader = (*JSONReader)(nil)
+var _ input.CodecProvider = (*JSONReader)(nil)
 
 func Init(path string, colName string, tsFormat string) (*JSONReader, error) {
 	f, e := os.Open(path)
@@ -29,7 +31,7 @@ func Init(path string, colName string, tsFormat string) (*JSONReader, error) {
 		return nil, e
 	}
 	r := bufio.NewReader(f)
-	return &JSONReader{r: r, p: &properties{tsColumn: colName, tsFormat: tsFormat}}, nil
+	return &JSONReader{f: f, r: r, p: &properties{tsColumn: colName, tsFormat: tsFormat}}, nil
 }
 
 func (j *JSONReader) ReadLineWithTS() (ts time.Time, data []byte, e error) {
@@ -57,3 +59,13 @@ func (j *JSONReader) ReadLine() (data []byte, e error) {
 	data, e = j.r.ReadBytes('\n')
 	return data, e
 }
+
+// Close closes the input file.
+func (j *JSONReader) Close() error {
+	return j.f.Close()
+}
+
+// Codec returns a codec for JSON objects read from the file.
+func (j *JSONReader) Codec() input.Codec {
+	return input.JSONCodec{}
+}
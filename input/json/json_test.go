@@ -62,3 +62,11 @@ func TestTSErrors(t *testing.T) {
 
 	assert.Error(t, e)
 }
+
+func TestClose(t *testing.T) {
+	r, _ := Init(testFile, testColumn, testTSFormat)
+
+	assert.NoError(t, r.Close())
+	// the file is already closed
+	assert.Error(t, r.Close())
+}
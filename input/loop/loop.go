@@ -0,0 +1,167 @@
+package loop
+
+import (
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/pburakov/playback/clock"
+	"github.com/pburakov/playback/input"
+)
+
+// Opener opens a new reader positioned at the beginning of the input file.
+type Opener func() (input.FileReader, error)
+
+// LoopReader replays the input file a number of times by reopening it once the
+// EOF is met. Timestamps returned by the reader are shifted forward on every
+// iteration, so that the timeline continues past the last timestamp of the
+// previous iteration, separated by a gap duration.
+type LoopReader struct {
+	open Opener
+	r    input.FileReader
+
+	// number of iterations to play, 0 is infinite
+	times uint
+	iter  uint
+	gap   time.Duration
+
+	// optional payload timestamp rewriting function
+	rewrite func([]byte, time.Time) ([]byte, error)
+	// optional clock set on every opened reader
+	clock clock.Clock
+
+	// number of lines read, first and last seen timestamps of the current iteration
+	lines uint64
+	first time.Time
+	last  time.Time
+	shift time.Duration
+}
+
+var _ input.FileReader = (*LoopReader)(nil)
+var _ input.CodecProvider = (*LoopReader)(nil)
+var _ input.Clocked = (*LoopReader)(nil)
+
+// Init opens the first reader and returns a looping reader playing the input
+// the given number of times, or indefinitely if times is 0. Gap is the delay
+// added between the last event of an iteration and the first event of the
+// next one.
+func Init(open Opener, times uint, gap time.Duration) (*LoopReader, error) {
+	r, e := open()
+	if e != nil {
+		return nil, e
+	}
+	return &LoopReader{open: open, r: r, times: times, iter: 1, gap: gap}, nil
+}
+
+// RewriteTS enables writing of the shifted timestamps into the timestamp column
+// of the binary data returned by the reader, so that event timestamps in the
+// payload keep increasing across iterations. The underlying reader must provide
+// a codec for its data.
+func (l *LoopReader) RewriteTS(col string, format string) error {
+	p, ok := l.r.(input.CodecProvider)
+	if !ok {
+		return errors.New("timestamp rewriting is not supported by the reader")
+	}
+	l.rewrite = input.TSRewriter(p.Codec(), col, format)
+	return nil
+}
+
+// Codec returns the codec of the underlying reader, or nil if the reader does
+// not provide one.
+func (l *LoopReader) Codec() input.Codec {
+	if p, ok := l.r.(input.CodecProvider); ok {
+		return p.Codec()
+	}
+	return nil
+}
+
+// SetClock sets the clock of the current reader and of the readers opened
+// for the next iterations.
+func (l *LoopReader) SetClock(c clock.Clock) {
+	l.clock = c
+	input.SetClock(l.r, c)
+}
+
+func (l *LoopReader) ReadLineWithTS() (ts time.Time, data []byte, e error) {
+	ts, data, e = l.r.ReadLineWithTS()
+	if e == io.EOF {
+		if e := l.next(); e != nil {
+			return ts, nil, e
+		}
+		return l.ReadLineWithTS()
+	}
+	if e != nil {
+		return ts, nil, e
+	}
+
+	if l.lines == 0 {
+		l.first = ts
+	}
+	l.lines++
+	l.last = ts
+
+	ts = ts.Add(l.shift)
+	if l.rewrite != nil && l.shift != 0 {
+		data, e = l.rewrite(data, ts)
+	}
+	return ts, data, e
+}
+
+func (l *LoopReader) ReadLine() (data []byte, e error) {
+	if l.rewrite != nil {
+		// Timestamps are required to keep the payload timeline consistent
+		_, data, e = l.ReadLineWithTS()
+		return data, e
+	}
+	data, e = l.r.ReadLine()
+	if e == io.EOF {
+		if e := l.next(); e != nil {
+			return nil, e
+		}
+		return l.ReadLine()
+	}
+	if e == nil {
+		l.lines++
+	}
+	return data, e
+}
+
+// Close closes the reader of the current iteration.
+func (l *LoopReader) Close() error {
+	return input.Close(l.r)
+}
+
+// Iteration returns the number of the current iteration, starting from 1.
+func (l *LoopReader) Iteration() uint {
+	return l.iter
+}
+
+// next closes the exhausted reader, reopens the input and advances the
+// timestamp shift for the next iteration. Returns io.EOF once all iterations
+// are completed.
+func (l *LoopReader) next() error {
+	if l.times != 0 && l.iter >= l.times {
+		return io.EOF
+	}
+	if l.lines == 0 {
+		// Avoid spinning on an empty input
+		return io.EOF
+	}
+	if e := input.Close(l.r); e != nil {
+		return e
+	}
+	r, e := l.open()
+	if e != nil {
+		return e
+	}
+	if l.clock != nil {
+		input.SetClock(r, l.clock)
+	}
+	l.r = r
+	l.iter++
+	l.shift += l.last.Sub(l.first) + l.gap
+	l.lines = 0
+	log.Printf("Starting iteration %d (timestamp shift is %s)", l.iter, l.shift)
+	return nil
+}
@@ -0,0 +1,130 @@
+package loop
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/input/csv"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testFile     = "../csv/input_test.csv"
+	testColumn   = "baz"
+	testTSFormat = "2006-01-02 15:04:05 UTC"
+	testGap      = time.Hour
+)
+
+func open() (input.FileReader, error) {
+	return csv.Init(testFile, testColumn, testTSFormat)
+}
+
+func TestLoopWithTS(t *testing.T) {
+	r, e := Init(open, 2, testGap)
+
+	assert.NoError(t, e)
+
+	first := time.Date(2019, 02, 04, 21, 16, 19, 0, time.UTC)
+	last := time.Date(2019, 02, 07, 12, 53, 31, 0, time.UTC)
+	shift := last.Sub(first) + testGap
+
+	ts, _, e := r.ReadLineWithTS()
+	assert.NoError(t, e)
+	assert.Equal(t, first, ts)
+
+	ts, _, e = r.ReadLineWithTS()
+	assert.NoError(t, e)
+	assert.Equal(t, last, ts)
+
+	ts, l, e := r.ReadLineWithTS()
+	assert.NoError(t, e)
+	assert.Equal(t, first.Add(shift), ts)
+	assert.Equal(t, `{"bar":"2","baz":"2019-02-04 21:16:19 UTC","foo":"1"}`, string(l))
+	assert.Equal(t, uint(2), r.Iteration())
+
+	ts, _, e = r.ReadLineWithTS()
+	assert.NoError(t, e)
+	assert.Equal(t, last.Add(shift), ts)
+
+	_, _, e = r.ReadLineWithTS()
+	assert.Equal(t, io.EOF, e)
+}
+
+func TestLoopRewriteTS(t *testing.T) {
+	r, _ := Init(open, 0, testGap)
+
+	assert.NoError(t, r.RewriteTS(testColumn, testTSFormat))
+
+	_, l, e := r.ReadLineWithTS()
+	assert.NoError(t, e)
+	assert.Equal(t, `{"bar":"2","baz":"2019-02-04 21:16:19 UTC","foo":"1"}`, string(l))
+
+	_, _ = r.ReadLine()
+	l, e = r.ReadLine()
+	assert.NoError(t, e)
+	assert.Equal(t, `{"bar":"2","baz":"2019-02-07 13:53:31 UTC","foo":"1"}`, string(l))
+
+	// Infinite loop keeps going
+	for i := 0; i < 10; i++ {
+		_, e = r.ReadLine()
+		assert.NoError(t, e)
+	}
+	assert.Equal(t, uint(7), r.Iteration())
+}
+
+func TestLoopReadLine(t *testing.T) {
+	r, _ := Init(open, 3, testGap)
+
+	for i := 0; i < 6; i++ {
+		l, e := r.ReadLine()
+		assert.NoError(t, e)
+		assert.NotNil(t, l)
+	}
+	_, e := r.ReadLine()
+	assert.Equal(t, io.EOF, e)
+}
+
+func TestInitErrors(t *testing.T) {
+	r, e := Init(func() (input.FileReader, error) {
+		return csv.Init("non_existent_file", testColumn, testTSFormat)
+	}, 1, testGap)
+
+	assert.Error(t, e)
+	assert.Nil(t, r)
+}
+
+// closeReader records whether the reader was closed.
+type closeReader struct {
+	input.FileReader
+	closed bool
+}
+
+func (r *closeReader) Close() error {
+	r.closed = true
+	return input.Close(r.FileReader)
+}
+
+func TestLoopClose(t *testing.T) {
+	var opened []*closeReader
+	r, _ := Init(func() (input.FileReader, error) {
+		c, e := open()
+		opened = append(opened, &closeReader{FileReader: c})
+		return opened[len(opened)-1], e
+	}, 0, testGap)
+
+	for i := 0; i < 6; i++ {
+		_, e := r.ReadLine()
+		assert.NoError(t, e)
+	}
+
+	assert.Len(t, opened, 3)
+	// readers of the previous iterations are closed
+	assert.True(t, opened[0].closed)
+	assert.True(t, opened[1].closed)
+	assert.False(t, opened[2].closed)
+
+	assert.NoError(t, r.Close())
+	assert.True(t, opened[2].closed)
+}
@@ -1,6 +1,11 @@
 package input
 
-import "time"
+import (
+	"io"
+	"time"
+
+	"github.com/pburakov/playback/clock"
+)
 
 type FileReader interface {
 	// ReadLineWithTS reads the next line from the input file, extracts the timestamp
@@ -12,3 +17,26 @@ type FileReader interface {
 
 	// TODO: add PeekNextTS() method
 }
+
+// Close closes the reader if it implements io.Closer, e.g. holds an open file.
+func Close(r FileReader) error {
+	if c, ok := r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Clocked is implemented by readers producing data that depends on the current
+// time, such as generated records, and by readers wrapping other readers.
+type Clocked interface {
+	// SetClock sets the clock providing the current time.
+	SetClock(c clock.Clock)
+}
+
+// SetClock sets the clock of the reader if it implements Clocked, so that the
+// data follows the playback clock, e.g. a fake or a virtual one.
+func SetClock(r FileReader, c clock.Clock) {
+	if cr, ok := r.(Clocked); ok {
+		cr.SetClock(c)
+	}
+}
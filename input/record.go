@@ -0,0 +1,76 @@
+package input
+
+import "strings"
+
+// primitives holds the names of Avro primitive types used as union keys.
+var primitives = map[string]bool{
+	"boolean": true, "int": true, "long": true, "float": true, "double": true, "bytes": true, "string": true,
+}
+
+// Unwrap returns the value wrapped into an Avro union (a map with a single
+// type name key, e.g. {"string": "foo"}) and the type name. Since unions can't
+// be reliably told apart from single-key objects, the caller decides whether
+// the value should be unwrapped.
+func Unwrap(v interface{}) (interface{}, string, bool) {
+	if m, ok := v.(map[string]interface{}); ok && len(m) == 1 {
+		for k, inner := range m {
+			return inner, k, true
+		}
+	}
+	return v, "", false
+}
+
+// IsPrimitiveUnion returns true if the value is an Avro union of a primitive type.
+func IsPrimitiveUnion(v interface{}) bool {
+	_, t, ok := Unwrap(v)
+	return ok && primitives[t]
+}
+
+// Parent returns the object holding the field at the given dot-separated path
+// and the field name, or nil if the path doesn't exist. Avro union wrappers of
+// nested records are stepped through.
+func Parent(rec map[string]interface{}, path string) (map[string]interface{}, string) {
+	segs := strings.Split(path, ".")
+	m := rec
+	for i := 0; i < len(segs)-1; i++ {
+		if m = Child(m, segs[i], segs[i+1]); m == nil {
+			return nil, ""
+		}
+	}
+	return m, segs[len(segs)-1]
+}
+
+// Child returns the nested object stored under the key, stepping through an
+// Avro union wrapper if the next key isn't found in the object itself.
+func Child(m map[string]interface{}, k string, next string) map[string]interface{} {
+	c, ok := m[k].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if _, found := c[next]; found {
+		return c
+	}
+	if inner, _, ok := Unwrap(c); ok {
+		if im, ok := inner.(map[string]interface{}); ok {
+			return im
+		}
+	}
+	return c
+}
+
+// Lookup returns the value of the field at the given dot-separated path.
+// Values wrapped into Avro unions of primitive types are unwrapped.
+func Lookup(rec map[string]interface{}, path string) (interface{}, bool) {
+	m, k := Parent(rec, path)
+	if m == nil {
+		return nil, false
+	}
+	v, found := m[k]
+	if !found {
+		return nil, false
+	}
+	if IsPrimitiveUnion(v) {
+		v, _, _ = Unwrap(v)
+	}
+	return v, true
+}
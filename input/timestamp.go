@@ -0,0 +1,95 @@
+package input
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timestamp extracts the value of the timestamp column from the record. String
+// values are parsed using the given layout, integer values (such as Avro
+// TIMESTAMP type) are treated as microseconds since unix epoch. Avro union
+// values are unwrapped.
+func Timestamp(rec map[string]interface{}, col string, format string) (time.Time, error) {
+	v, found := rec[col]
+	if !found {
+		return time.Unix(0, 0), fmt.Errorf("timestamp column %q not found", col)
+	}
+	return parseTimestamp(v, format)
+}
+
+// parseTimestamp deserializes a timestamp value.
+func parseTimestamp(v interface{}, format string) (time.Time, error) {
+	switch t := v.(type) {
+	case string:
+		return time.Parse(format, t)
+	case int64:
+		return time.Unix(t/1000000, 1000*(t%1000000)).UTC(), nil
+	case map[string]interface{}:
+		for _, v := range t {
+			return parseTimestamp(v, format)
+		}
+		return time.Unix(0, 0), fmt.Errorf("empty timestamp value %q", t)
+	default:
+		return time.Unix(0, 0), fmt.Errorf("unsupported timestamp value %q", t)
+	}
+}
+
+// SetTimestamp replaces the value of the timestamp column in the record with
+// the given timestamp, preserving the serialization of the original value.
+// String values are formatted using the given layout, integer values (such as
+// Avro TIMESTAMP type) are set as microseconds since unix epoch. Avro union
+// values are replaced with a new union of the same type.
+func SetTimestamp(rec map[string]interface{}, col string, format string, ts time.Time) error {
+	v, found := rec[col]
+	if !found {
+		return fmt.Errorf("timestamp column %q not found", col)
+	}
+	nv, e := timestampValue(v, format, ts)
+	if e != nil {
+		return e
+	}
+	rec[col] = nv
+	return nil
+}
+
+// timestampValue returns the timestamp serialized the same way as the old value.
+func timestampValue(old interface{}, format string, ts time.Time) (interface{}, error) {
+	switch t := old.(type) {
+	case string:
+		return ts.Format(format), nil
+	case int64:
+		return toMicros(ts), nil
+	case map[string]interface{}:
+		// Avro union, e.g. {"long": 1549907802053944}
+		for k, v := range t {
+			nv, e := timestampValue(v, format, ts)
+			if e != nil {
+				return nil, e
+			}
+			return map[string]interface{}{k: nv}, nil
+		}
+		return nil, fmt.Errorf("empty timestamp value %q", t)
+	default:
+		return nil, fmt.Errorf("unsupported timestamp value %q", t)
+	}
+}
+
+// toMicros converts a timestamp to microseconds since unix epoch.
+func toMicros(ts time.Time) int64 {
+	return ts.UnixNano() / 1000
+}
+
+// TSRewriter returns a function that decodes binary data with the given codec,
+// replaces the value of the timestamp column and encodes the data back.
+func TSRewriter(c Codec, col string, format string) func([]byte, time.Time) ([]byte, error) {
+	return func(d []byte, ts time.Time) ([]byte, error) {
+		rec, e := c.Decode(d)
+		if e != nil {
+			return nil, e
+		}
+		if e := SetTimestamp(rec, col, format, ts); e != nil {
+			return nil, e
+		}
+		return c.Encode(rec)
+	}
+}
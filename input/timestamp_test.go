@@ -0,0 +1,66 @@
+package input
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testTS = time.Date(2019, 02, 11, 17, 56, 42, 53944000, time.UTC)
+
+func TestSetTimestamp(t *testing.T) {
+	rec := map[string]interface{}{
+		"foo": "2006-01-02",
+		"bar": map[string]interface{}{"long": int64(0)},
+		"baz": map[string]interface{}{"string": ""},
+	}
+
+	assert.NoError(t, SetTimestamp(rec, "foo", "2006-01-02", testTS))
+	assert.NoError(t, SetTimestamp(rec, "bar", "doesn't matter", testTS))
+	assert.NoError(t, SetTimestamp(rec, "baz", time.RFC3339, testTS))
+
+	assert.Equal(t, map[string]interface{}{
+		"foo": "2019-02-11",
+		"bar": map[string]interface{}{"long": int64(1549907802053944)},
+		"baz": map[string]interface{}{"string": "2019-02-11T17:56:42Z"},
+	}, rec)
+}
+
+func TestSetTimestampErrors(t *testing.T) {
+	rec := map[string]interface{}{"foo": 42.42}
+
+	assert.Error(t, SetTimestamp(rec, "bar", time.RFC3339, testTS))
+	assert.Error(t, SetTimestamp(rec, "foo", time.RFC3339, testTS))
+}
+
+func TestTSRewriter(t *testing.T) {
+	rw := TSRewriter(JSONCodec{}, "ts", time.RFC3339)
+
+	d, e := rw([]byte(`{"ts":"2006-01-02T15:04:05Z","val":42}`), testTS)
+
+	assert.NoError(t, e)
+	assert.Equal(t, `{"ts":"2019-02-11T17:56:42Z","val":42}`, string(d))
+}
+
+func TestTimestamp(t *testing.T) {
+	rec := map[string]interface{}{
+		"foo": "2019-02-11T17:56:42.053944Z",
+		"bar": map[string]interface{}{"long": int64(1549907802053944)},
+		"baz": 42.42,
+	}
+
+	ts, e := Timestamp(rec, "foo", time.RFC3339Nano)
+	assert.NoError(t, e)
+	assert.Equal(t, testTS, ts)
+
+	ts, e = Timestamp(rec, "bar", "doesn't matter")
+	assert.NoError(t, e)
+	assert.Equal(t, testTS, ts)
+
+	_, e = Timestamp(rec, "baz", time.RFC3339Nano)
+	assert.Error(t, e)
+
+	_, e = Timestamp(rec, "faz", time.RFC3339Nano)
+	assert.Error(t, e)
+}
@@ -0,0 +1,84 @@
+package amqp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+	"github.com/pburakov/playback/test"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTimeout = 5 * time.Second
+
+func TestPublish(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	url := "amqp://guest:guest@" + test.BindContainer("rabbitmq:3", "", "5672") + "/"
+
+	conn, e := amqp091.Dial(url)
+	assert.NoError(t, e)
+	defer conn.Close()
+	ch, e := conn.Channel()
+	assert.NoError(t, e)
+	assert.NoError(t, ch.ExchangeDeclare("orders", "topic", false, true, false, false, nil))
+	q, e := ch.QueueDeclare("", false, true, true, false, nil)
+	assert.NoError(t, e)
+	assert.NoError(t, ch.QueueBind(q.Name, "orders.eu", "orders", false, nil))
+
+	s, e := Init(url, "orders", "orders.{{region}}", input.JSONCodec{}, testTimeout)
+	assert.NoError(t, e)
+	defer s.Close()
+	assert.NoError(t, s.Confirm())
+	assert.NoError(t, s.Header("user", "user-{{user.id}}"))
+	s.ContentType("application/json")
+	s.Persistent(true)
+
+	e = s.Publish(context.Background(), &output.Message{
+		Data:       []byte(`{"region":"eu","user":{"id":42}}`),
+		Attributes: map[string]string{"orig": "foo"},
+	})
+	assert.NoError(t, e)
+
+	d, found, e := ch.Get(q.Name, true)
+	assert.NoError(t, e)
+	assert.True(t, found)
+	assert.Equal(t, `{"region":"eu","user":{"id":42}}`, string(d.Body))
+	assert.Equal(t, "application/json", d.ContentType)
+	assert.Equal(t, amqp091.Persistent, d.DeliveryMode)
+	assert.Equal(t, "user-42", d.Headers["user"])
+	assert.Equal(t, "foo", d.Headers["orig"])
+
+	e = s.Publish(context.Background(), &output.Message{Data: []byte(`{"user":{"id":42}}`)})
+	assert.Error(t, e)
+
+	e = s.Publish(context.Background(), &output.Message{Data: []byte(`{"region":"us","user":{"id":42}}`)})
+	assert.EqualError(t, e, "message was returned by the broker: NO_ROUTE")
+}
+
+func TestPublishing(t *testing.T) {
+	key, _ := output.ParseTemplate("orders.{{region}}")
+	h, _ := output.ParseTemplate("{{user.id}}")
+	a := &AMQP{key: key, headers: map[string]*output.Template{"user": h}, codec: input.JSONCodec{}}
+	ts := time.Date(2019, 2, 11, 0, 0, 0, 0, time.UTC)
+
+	p, k, e := a.publishing(&output.Message{
+		Data:       []byte(`{"region":"eu","user":{"id":42}}`),
+		Attributes: map[string]string{"orig": "foo"},
+		Timestamp:  ts,
+	})
+
+	assert.NoError(t, e)
+	assert.Equal(t, "orders.eu", k)
+	assert.Equal(t, amqp091.Table{"orig": "foo", "user": "42"}, p.Headers)
+	assert.Equal(t, ts, p.Timestamp)
+	assert.Equal(t, uint8(0), p.DeliveryMode)
+
+	_, _, e = a.publishing(&output.Message{Data: []byte(`{"region":"eu"}`)})
+
+	assert.Error(t, e)
+}
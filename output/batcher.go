@@ -0,0 +1,121 @@
+package output
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Batcher groups the items added by concurrent publishers into batches. A
+// batch is sent once it is full, or once the delay passes since its first item
+// was added. Batches are sent by the send function, which returns the results
+// of the items in order, or an error failing the whole batch.
+type Batcher[T, R any] struct {
+	size  int
+	delay time.Duration
+	send  func(context.Context, []T) ([]R, error)
+
+	maxWeight int
+	weight    func(T) int
+
+	mu    sync.Mutex
+	batch *batch[T, R]
+}
+
+// batch is a group of items sent at once, with the context of the first
+// publisher.
+type batch[T, R any] struct {
+	ctx     context.Context
+	items   []T
+	weight  int
+	results []R
+	done    chan struct{}
+	e       error
+}
+
+// InitBatcher returns a batcher sending batches of up to the given size with
+// the given send function.
+func InitBatcher[T, R any](size int, delay time.Duration, send func(context.Context, []T) ([]R, error)) *Batcher[T, R] {
+	return &Batcher[T, R]{size: size, delay: delay, send: send}
+}
+
+// Limit caps the total weight of a batch, e.g. its size in bytes, measured
+// with the given function. A batch is sent early when the next item would
+// exceed the limit. Items exceeding the limit on their own are sent alone.
+func (b *Batcher[T, R]) Limit(max int, weight func(T) int) {
+	b.maxWeight = max
+	b.weight = weight
+}
+
+// Add adds the item to the current batch and returns once the batch is sent,
+// or once the context is cancelled. It returns the result of the item along
+// with the number of items in the batch. The first publisher's context is
+// used for sending the batch.
+func (b *Batcher[T, R]) Add(ctx context.Context, item T) (R, int, error) {
+	bt, i := b.add(ctx, item)
+	var r R
+	select {
+	case <-bt.done:
+	case <-ctx.Done():
+		return r, 0, ctx.Err()
+	}
+	if bt.e != nil {
+		return r, len(bt.items), bt.e
+	}
+	if i < len(bt.results) {
+		r = bt.results[i]
+	}
+	return r, len(bt.items), nil
+}
+
+// add appends the item to the current batch and returns the batch along with
+// the item index, sending the batch if it is full. The first item of a batch
+// schedules sending it after the delay.
+func (b *Batcher[T, R]) add(ctx context.Context, item T) (*batch[T, R], int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w := 0
+	if b.weight != nil {
+		w = b.weight(item)
+		if bt := b.batch; bt != nil && bt.weight+w > b.maxWeight {
+			b.batch = nil
+			go b.exec(bt)
+		}
+	}
+
+	bt := b.batch
+	if bt == nil {
+		bt = &batch[T, R]{ctx: ctx, done: make(chan struct{})}
+		b.batch = bt
+		time.AfterFunc(b.delay, func() {
+			b.flush(bt)
+		})
+	}
+	bt.items = append(bt.items, item)
+	bt.weight += w
+	i := len(bt.items) - 1
+	if len(bt.items) >= b.size {
+		b.batch = nil
+		go b.exec(bt)
+	}
+	return bt, i
+}
+
+// flush sends the batch, unless it was already sent because it was full.
+func (b *Batcher[T, R]) flush(bt *batch[T, R]) {
+	b.mu.Lock()
+	if b.batch != bt {
+		b.mu.Unlock()
+		return
+	}
+	b.batch = nil
+	b.mu.Unlock()
+	b.exec(bt)
+}
+
+// exec sends the batch and notifies the waiting publishers.
+func (b *Batcher[T, R]) exec(bt *batch[T, R]) {
+	bt.results, bt.e = b.send(bt.ctx, bt.items)
+	close(bt.done)
+}
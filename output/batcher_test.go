@@ -0,0 +1,95 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatcher(t *testing.T) {
+	var mu sync.Mutex
+	var sent [][]int
+	b := InitBatcher(3, time.Hour, func(_ context.Context, items []int) ([]int, error) {
+		mu.Lock()
+		sent = append(sent, items)
+		mu.Unlock()
+		results := make([]int, len(items))
+		for i, v := range items {
+			results[i] = 10 * v
+		}
+		return results, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			r, n, e := b.Add(context.Background(), i)
+			assert.NoError(t, e)
+			assert.Equal(t, 10*i, r)
+			assert.Equal(t, 3, n)
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, sent, 1)
+	assert.ElementsMatch(t, []int{1, 2, 3}, sent[0])
+}
+
+func TestBatcherDelay(t *testing.T) {
+	b := InitBatcher(3, 10*time.Millisecond, func(_ context.Context, items []int) ([]struct{}, error) {
+		return nil, errors.New("send error")
+	})
+
+	// an incomplete batch is sent after the delay
+	_, n, e := b.Add(context.Background(), 1)
+
+	assert.EqualError(t, e, "send error")
+	assert.Equal(t, 1, n)
+}
+
+func TestBatcherLimit(t *testing.T) {
+	var mu sync.Mutex
+	var sent [][]string
+	b := InitBatcher(3, 10*time.Millisecond, func(_ context.Context, items []string) ([]struct{}, error) {
+		mu.Lock()
+		sent = append(sent, items)
+		mu.Unlock()
+		return nil, nil
+	})
+	b.Limit(5, func(s string) int { return len(s) })
+
+	var wg sync.WaitGroup
+	for _, s := range []string{"foo", "bar", "bazbaz"} {
+		wg.Add(1)
+		go func(s string) {
+			_, _, e := b.Add(context.Background(), s)
+			assert.NoError(t, e)
+			wg.Done()
+		}(s)
+	}
+	wg.Wait()
+
+	// every item takes the batch over the limit
+	assert.Len(t, sent, 3)
+	for _, items := range sent {
+		assert.Len(t, items, 1)
+	}
+}
+
+func TestBatcherCancel(t *testing.T) {
+	b := InitBatcher(3, time.Hour, func(_ context.Context, items []int) ([]struct{}, error) {
+		return nil, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, e := b.Add(ctx, 1)
+
+	assert.Equal(t, context.Canceled, e)
+}
@@ -0,0 +1,102 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+)
+
+// File is a sink writing messages to a file or a stream as newline delimited
+// JSON. Every line holds the message tag, send time (the intended send time,
+// if known), attributes and the payload. JSON payloads are written as is,
+// other payloads are decoded with the codec, or written as base64 strings if
+// no codec is given.
+type File struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	c     io.Closer
+	codec input.Codec
+}
+
+var _ output.Sink = (*File)(nil)
+
+// line is a single message written by the sink.
+type line struct {
+	Tag        string            `json:"tag,omitempty"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Data       interface{}       `json:"data"`
+}
+
+// Init returns a sink writing to the given writer, e.g. os.Stdout. The codec
+// can be nil.
+func Init(w io.Writer, codec input.Codec) *File {
+	return &File{w: bufio.NewWriter(w), codec: codec}
+}
+
+// Open creates or truncates the file at the given path and returns a sink
+// writing to it. The sink must be closed once the playback is completed.
+func Open(path string, codec input.Codec) (*File, error) {
+	f, e := os.Create(path)
+	if e != nil {
+		return nil, e
+	}
+	s := Init(f, codec)
+	s.c = f
+	return s, nil
+}
+
+// Publish writes the message, flushing it immediately, so that the output
+// keeps the timing of the playback.
+func (f *File) Publish(ctx context.Context, m *output.Message) error {
+	ts := m.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	d, e := f.data(m.Data)
+	if e != nil {
+		return e
+	}
+	b, e := json.Marshal(&line{Tag: m.Tag, Timestamp: ts, Attributes: m.Attributes, Data: d})
+	if e != nil {
+		return e
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, e := f.w.Write(append(b, '\n')); e != nil {
+		return e
+	}
+	return f.w.Flush()
+}
+
+// data returns the payload value written to the output.
+func (f *File) data(d []byte) (interface{}, error) {
+	if json.Valid(d) {
+		return json.RawMessage(d), nil
+	}
+	if f.codec != nil {
+		return f.codec.Decode(d)
+	}
+	return d, nil
+}
+
+// Close closes the underlying file, if the sink was opened with Open.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e := f.w.Flush(); e != nil {
+		return e
+	}
+	if f.c != nil {
+		return f.c.Close()
+	}
+	return nil
+}
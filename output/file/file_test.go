@@ -0,0 +1,82 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTime = time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+
+func TestPublish(t *testing.T) {
+	var b bytes.Buffer
+	s := Init(&b, nil)
+
+	e := s.Publish(context.Background(), &output.Message{
+		Tag:        "no=1",
+		Data:       []byte("{\"foo\": 1}\n"),
+		Attributes: map[string]string{"orig": "bar"},
+		Timestamp:  testTime,
+	})
+
+	assert.NoError(t, e)
+
+	e = s.Publish(context.Background(), &output.Message{Tag: "no=2", Data: []byte("foo"), Timestamp: testTime})
+
+	assert.NoError(t, e)
+	assert.Equal(t, `{"tag":"no=1","timestamp":"2026-10-18T15:00:00Z","attributes":{"orig":"bar"},"data":{"foo":1}}
+{"tag":"no=2","timestamp":"2026-10-18T15:00:00Z","data":"Zm9v"}
+`, b.String())
+}
+
+func TestPublishWithCodec(t *testing.T) {
+	var b bytes.Buffer
+	s := Init(&b, testCodec{})
+
+	e := s.Publish(context.Background(), &output.Message{Data: []byte("foo"), Timestamp: testTime})
+
+	assert.NoError(t, e)
+	assert.Equal(t, `{"timestamp":"2026-10-18T15:00:00Z","data":{"decoded":"foo"}}`+"\n", b.String())
+}
+
+func TestOpen(t *testing.T) {
+	dir, e := ioutil.TempDir("", "playback")
+	assert.NoError(t, e)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "out.json")
+	s, e := Open(path, nil)
+	assert.NoError(t, e)
+
+	assert.NoError(t, s.Publish(context.Background(), &output.Message{Data: []byte("1"), Timestamp: testTime}))
+	assert.NoError(t, s.Close())
+
+	b, e := ioutil.ReadFile(path)
+
+	assert.NoError(t, e)
+	assert.Equal(t, `{"timestamp":"2026-10-18T15:00:00Z","data":1}`+"\n", string(b))
+
+	_, e = Open(filepath.Join(dir, "missing", "out.json"), nil)
+
+	assert.Error(t, e)
+}
+
+type testCodec struct{}
+
+var _ input.Codec = testCodec{}
+
+func (testCodec) Decode(d []byte) (map[string]interface{}, error) {
+	return map[string]interface{}{"decoded": string(d)}, nil
+}
+
+func (testCodec) Encode(m map[string]interface{}) ([]byte, error) {
+	return nil, nil
+}
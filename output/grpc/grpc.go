@@ -0,0 +1,311 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// GRPC is a sink calling a unary or client-streaming gRPC method. Request
+// messages are built from the records using the message descriptors of a
+// descriptor set, so no generated code is required. Unary methods are called
+// once per message. Client-streaming methods are called once, and the messages
+// are sent on the stream until the sink is closed.
+type GRPC struct {
+	target   string
+	creds    credentials.TransportCredentials
+	timeout  time.Duration
+	conn     *grpc.ClientConn
+	method   protoreflect.MethodDescriptor
+	codec    input.Codec
+	metadata map[string]*output.Template
+	discard  bool
+
+	mu     sync.Mutex
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+var _ output.Sink = (*GRPC)(nil)
+
+// Init returns a sink calling the method of the server at the given target,
+// e.g. "localhost:50051". The method is given by its full name, e.g.
+// "ingest.v1.Ingest/Send", and looked up in the descriptor set file, produced
+// with protoc --descriptor_set_out --include_imports. The codec is used to
+// decode the records which aren't JSON, and for templates. It can be nil if
+// neither is needed. The sink must be connected with Connect before
+// publishing.
+func Init(target string, descriptorSet string, method string, codec input.Codec, to time.Duration) (*GRPC, error) {
+	m, e := findMethod(descriptorSet, method)
+	if e != nil {
+		return nil, e
+	}
+	if m.IsStreamingServer() {
+		return nil, fmt.Errorf("server-streaming method %s is not supported", m.FullName())
+	}
+	return &GRPC{
+		target:   target,
+		timeout:  to,
+		method:   m,
+		codec:    codec,
+		metadata: make(map[string]*output.Template),
+	}, nil
+}
+
+// TLS enables TLS with the given configuration. By default, the connection is
+// not encrypted.
+func (g *GRPC) TLS(c *tls.Config) {
+	g.creds = credentials.NewTLS(c)
+}
+
+// Metadata adds a request metadata entry. The value is a template, which
+// placeholders are replaced with the record field values, e.g. "{{user.id}}".
+// Metadata of a client-streaming call is derived from the first record sent
+// on the stream.
+func (g *GRPC) Metadata(name string, value string) error {
+	t, e := output.ParseTemplate(value)
+	if e != nil {
+		return e
+	}
+	g.metadata[strings.ToLower(name)] = t
+	return nil
+}
+
+// DiscardUnknown ignores the record fields missing in the request message,
+// instead of failing.
+func (g *GRPC) DiscardUnknown(d bool) {
+	g.discard = d
+}
+
+// Connect connects to the server.
+func (g *GRPC) Connect() error {
+	opts := []grpc.DialOption{grpc.WithBlock()}
+	if g.creds != nil {
+		opts = append(opts, grpc.WithTransportCredentials(g.creds))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+	conn, e := grpc.DialContext(ctx, g.target, opts...)
+	if e != nil {
+		return fmt.Errorf("error connecting to %s: %s", g.target, e)
+	}
+	g.conn = conn
+	return nil
+}
+
+// Publish sends the message. For unary methods, it returns once the call
+// completes or the timeout passes. For client-streaming methods, it returns
+// once the message is sent on the stream, which is opened by the first message.
+func (g *GRPC) Publish(ctx context.Context, m *output.Message) error {
+	req, rec, e := g.request(m.Data)
+	if e != nil {
+		return e
+	}
+	md, e := g.md(rec, m)
+	if e != nil {
+		return e
+	}
+
+	if !g.method.IsStreamingClient() {
+		res := dynamicpb.NewMessage(g.method.Output())
+		ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(ctx, md), g.timeout)
+		defer cancel()
+		if e := g.conn.Invoke(ctx, fullMethod(g.method), req, res, grpc.CallCustomCodec(codec{})); e != nil {
+			return e
+		}
+		log.Printf("Called %s (%s)", g.method.FullName(), m.Tag)
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stream == nil {
+		if e := g.open(md); e != nil {
+			return e
+		}
+	}
+	if e := g.stream.SendMsg(req); e != nil {
+		if e == io.EOF {
+			// the stream was terminated by the server, its status is
+			// received with the response
+			if e = g.closeStream(); e == nil {
+				e = errors.New("stream was closed by the server")
+			}
+		}
+		g.stream = nil
+		return e
+	}
+	log.Printf("Sent message on %s stream (%s)", g.method.FullName(), m.Tag)
+	return nil
+}
+
+// open opens the client stream. The stream isn't bound to the publish context
+// and lasts until the sink is closed.
+func (g *GRPC) open(md metadata.MD) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	desc := &grpc.StreamDesc{StreamName: string(g.method.Name()), ClientStreams: true}
+	s, e := g.conn.NewStream(metadata.NewOutgoingContext(ctx, md), desc, fullMethod(g.method), grpc.CallCustomCodec(codec{}))
+	if e != nil {
+		cancel()
+		return e
+	}
+	g.stream, g.cancel = s, cancel
+	return nil
+}
+
+// closeStream half-closes the client stream and receives the response.
+func (g *GRPC) closeStream() error {
+	defer g.cancel()
+	if e := g.stream.CloseSend(); e != nil {
+		return e
+	}
+	res := dynamicpb.NewMessage(g.method.Output())
+	if e := g.stream.RecvMsg(res); e != nil {
+		return e
+	}
+	b, _ := protojson.Marshal(res)
+	log.Printf("Closed %s stream, response: %s", g.method.FullName(), b)
+	return nil
+}
+
+// request returns the request message built from the record. Records which
+// aren't JSON are decoded with the codec and converted to JSON first. The
+// decoded record is returned if needed for templates.
+func (g *GRPC) request(d []byte) (*dynamicpb.Message, map[string]interface{}, error) {
+	ts := make([]*output.Template, 0, len(g.metadata))
+	for _, t := range g.metadata {
+		ts = append(ts, t)
+	}
+	rec, e := output.Record(g.codec, d, ts...)
+	if e != nil {
+		return nil, nil, fmt.Errorf("unable to decode record for metadata templates: %s", e)
+	}
+
+	if !json.Valid(d) {
+		if g.codec == nil {
+			return nil, nil, errors.New("unable to build request from a record which isn't json")
+		}
+		if rec == nil {
+			if rec, e = g.codec.Decode(d); e != nil {
+				return nil, nil, fmt.Errorf("unable to decode record: %s", e)
+			}
+		}
+		if d, e = json.Marshal(rec); e != nil {
+			return nil, nil, e
+		}
+	}
+
+	req := dynamicpb.NewMessage(g.method.Input())
+	opts := protojson.UnmarshalOptions{DiscardUnknown: g.discard}
+	if e := opts.Unmarshal(d, req); e != nil {
+		return nil, nil, fmt.Errorf("unable to build %s request: %s", g.method.Input().FullName(), e)
+	}
+	return req, rec, nil
+}
+
+// md returns the request metadata for the message. Message attributes are
+// added as metadata entries.
+func (g *GRPC) md(rec map[string]interface{}, m *output.Message) (metadata.MD, error) {
+	md := metadata.MD{}
+	for k, v := range m.Attributes {
+		md.Append(strings.ToLower(k), v)
+	}
+	for k, t := range g.metadata {
+		v, e := t.Execute(rec)
+		if e != nil {
+			return nil, e
+		}
+		md[k] = []string{v}
+	}
+	return md, nil
+}
+
+// Close closes the client stream, if any, and the connection.
+func (g *GRPC) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var e error
+	if g.stream != nil {
+		e = g.closeStream()
+		g.stream = nil
+	}
+	if g.conn != nil {
+		if ce := g.conn.Close(); e == nil {
+			e = ce
+		}
+	}
+	return e
+}
+
+// findMethod loads the descriptor set file and returns the descriptor of the
+// method given by its full name, either in the form of "pkg.Service/Method"
+// or "pkg.Service.Method".
+func findMethod(path string, name string) (protoreflect.MethodDescriptor, error) {
+	b, e := ioutil.ReadFile(path)
+	if e != nil {
+		return nil, e
+	}
+	var set descriptorpb.FileDescriptorSet
+	if e := proto.Unmarshal(b, &set); e != nil {
+		return nil, fmt.Errorf("error parsing descriptor set %q: %s", path, e)
+	}
+	files, e := protodesc.NewFiles(&set)
+	if e != nil {
+		return nil, fmt.Errorf("invalid descriptor set %q: %s", path, e)
+	}
+
+	n := strings.Replace(strings.TrimPrefix(name, "/"), "/", ".", 1)
+	d, e := files.FindDescriptorByName(protoreflect.FullName(n))
+	if e != nil {
+		return nil, fmt.Errorf("method %q not found in descriptor set %q", name, path)
+	}
+	m, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a method", name)
+	}
+	return m, nil
+}
+
+// fullMethod returns the method name in the form used by gRPC, e.g.
+// "/pkg.Service/Method".
+func fullMethod(m protoreflect.MethodDescriptor) string {
+	return fmt.Sprintf("/%s/%s", m.Parent().FullName(), m.Name())
+}
+
+// codec marshals the dynamic messages with the protobuf runtime, which the
+// default codec of gRPC doesn't support.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	return proto.Marshal(v.(proto.Message))
+}
+
+func (codec) Unmarshal(d []byte, v interface{}) error {
+	return proto.Unmarshal(d, v.(proto.Message))
+}
+
+func (codec) String() string {
+	return "proto"
+}
@@ -0,0 +1,233 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const testTimeout = 5 * time.Second
+
+// testDescriptor returns the descriptor set of the test service:
+//
+//	message Event { string id = 1; int64 value = 2; }
+//	message Ack { int64 count = 1; }
+//	service Ingest {
+//	  rpc Send(Event) returns (Ack);
+//	  rpc Stream(stream Event) returns (Ack);
+//	  rpc Watch(Event) returns (stream Ack);
+//	}
+func testDescriptor() *descriptorpb.FileDescriptorSet {
+	field := func(name string, n int32, t descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(n),
+			Type:     t.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+	}
+	method := func(name string, in string, out string, cs bool, ss bool) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(in),
+			OutputType:      proto.String(out),
+			ClientStreaming: proto.Bool(cs),
+			ServerStreaming: proto.Bool(ss),
+		}
+	}
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("ingest.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Event"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+			}},
+			{Name: proto.String("Ack"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("count", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Ingest"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("Send", ".test.Event", ".test.Ack", false, false),
+				method("Stream", ".test.Event", ".test.Ack", true, false),
+				method("Watch", ".test.Event", ".test.Ack", false, true),
+			},
+		}},
+	}}}
+}
+
+// testServer records the requests received by the test service as JSON and
+// acknowledges them with the count of the received events. A hanging server
+// never responds.
+type testServer struct {
+	hang     bool
+	mu       sync.Mutex
+	events   []string
+	metadata []metadata.MD
+}
+
+func (s *testServer) handle(_ interface{}, stream grpc.ServerStream) error {
+	files, _ := protodesc.NewFiles(testDescriptor())
+	event, _ := files.FindDescriptorByName("test.Event")
+	ack, _ := files.FindDescriptorByName("test.Ack")
+	msg := event.(protoreflect.MessageDescriptor)
+
+	if s.hang {
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.mu.Lock()
+	s.metadata = append(s.metadata, md)
+	s.mu.Unlock()
+
+	var n int64
+	for {
+		req := dynamicpb.NewMessage(msg)
+		if e := stream.RecvMsg(req); e == io.EOF {
+			break
+		} else if e != nil {
+			return e
+		}
+		b, _ := protojson.Marshal(req)
+		s.mu.Lock()
+		s.events = append(s.events, string(b))
+		s.mu.Unlock()
+		n++
+	}
+	res := dynamicpb.NewMessage(ack.(protoreflect.MessageDescriptor))
+	res.Set(res.Descriptor().Fields().ByName("count"), protoreflect.ValueOfInt64(n))
+	if n == 0 {
+		return status.Error(codes.InvalidArgument, "no events")
+	}
+	return stream.SendMsg(res)
+}
+
+// startServer starts the test service and writes its descriptor set file.
+// It returns the server address and the path to the file.
+func startServer(t *testing.T, s *testServer) (string, string, func()) {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, e)
+	srv := grpc.NewServer(grpc.CustomCodec(codec{}), grpc.UnknownServiceHandler(s.handle))
+	go srv.Serve(l)
+
+	dir, e := ioutil.TempDir("", "playback")
+	assert.NoError(t, e)
+	path := filepath.Join(dir, "ingest.pb")
+	b, _ := proto.Marshal(testDescriptor())
+	assert.NoError(t, ioutil.WriteFile(path, b, 0644))
+
+	return l.Addr().String(), path, func() {
+		srv.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestPublishUnary(t *testing.T) {
+	s := new(testServer)
+	addr, path, stop := startServer(t, s)
+	defer stop()
+
+	g, e := Init(addr, path, "test.Ingest/Send", input.JSONCodec{}, testTimeout)
+	assert.NoError(t, e)
+	assert.NoError(t, g.Metadata("X-User", "user-{{id}}"))
+	assert.NoError(t, g.Connect())
+	defer g.Close()
+
+	e = g.Publish(context.Background(), &output.Message{
+		Data:       []byte(`{"id":"e1","value":42}`),
+		Attributes: map[string]string{"orig": "foo"},
+	})
+
+	assert.NoError(t, e)
+	assert.Len(t, s.events, 1)
+	assert.JSONEq(t, `{"id":"e1","value":"42"}`, s.events[0])
+	assert.Equal(t, []string{"user-e1"}, s.metadata[0].Get("x-user"))
+	assert.Equal(t, []string{"foo"}, s.metadata[0].Get("orig"))
+
+	e = g.Publish(context.Background(), &output.Message{Data: []byte(`{"id":"e2","extra":1}`)})
+
+	assert.Error(t, e)
+
+	g.DiscardUnknown(true)
+	e = g.Publish(context.Background(), &output.Message{Data: []byte(`{"id":"e2","extra":1}`)})
+
+	assert.NoError(t, e)
+	assert.JSONEq(t, `{"id":"e2"}`, s.events[1])
+}
+
+func TestPublishTimeout(t *testing.T) {
+	addr, path, stop := startServer(t, &testServer{hang: true})
+	defer stop()
+
+	g, e := Init(addr, path, "test.Ingest/Send", nil, 50*time.Millisecond)
+	assert.NoError(t, e)
+	assert.NoError(t, g.Connect())
+	defer g.Close()
+
+	e = g.Publish(context.Background(), &output.Message{Data: []byte(`{"id":"e1"}`)})
+
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(e))
+}
+
+func TestPublishStream(t *testing.T) {
+	s := new(testServer)
+	addr, path, stop := startServer(t, s)
+	defer stop()
+
+	g, e := Init(addr, path, "/test.Ingest/Stream", nil, testTimeout)
+	assert.NoError(t, e)
+	assert.NoError(t, g.Connect())
+
+	for _, d := range []string{`{"id":"e1"}`, `{"id":"e2"}`} {
+		assert.NoError(t, g.Publish(context.Background(), &output.Message{Data: []byte(d)}))
+	}
+	assert.NoError(t, g.Close())
+
+	assert.Len(t, s.events, 2)
+	assert.JSONEq(t, `{"id":"e1"}`, s.events[0])
+	assert.JSONEq(t, `{"id":"e2"}`, s.events[1])
+	// a single call is made
+	assert.Len(t, s.metadata, 1)
+}
+
+func TestInit(t *testing.T) {
+	_, path, stop := startServer(t, new(testServer))
+	defer stop()
+
+	_, e := Init("", path, "test.Ingest/Watch", nil, testTimeout)
+	assert.Error(t, e)
+
+	_, e = Init("", path, "test.Ingest/Missing", nil, testTimeout)
+	assert.Error(t, e)
+
+	_, e = Init("", path, "test.Event", nil, testTimeout)
+	assert.Error(t, e)
+
+	_, e = Init("", "missing.pb", "test.Ingest/Send", nil, testTimeout)
+	assert.Error(t, e)
+}
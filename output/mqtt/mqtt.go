@@ -0,0 +1,124 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang"
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+)
+
+// MQTT is a sink publishing messages to an MQTT broker. Topics can be derived
+// from the record fields, e.g. to replay the telemetry of every device to its
+// own topic. The sink speaks MQTT 3.1.1 only, which has no message headers,
+// so the message attributes are not sent.
+type MQTT struct {
+	opts     *mqtt.ClientOptions
+	client   mqtt.Client
+	topic    *output.Template
+	codec    input.Codec
+	qos      byte
+	retained bool
+}
+
+var _ output.Sink = (*MQTT)(nil)
+
+// Init returns a sink publishing to the broker at the given URL, e.g.
+// "tcp://localhost:1883" or "ssl://localhost:8883". The topic is a template,
+// which placeholders are replaced with the record field values, e.g.
+// "devices/{{device.id}}/telemetry". The codec is used to decode the records
+// for templates, and can be nil if no templates are used. The sink must be
+// connected with Connect before publishing.
+func Init(broker string, topic string, codec input.Codec, to time.Duration) (*MQTT, error) {
+	t, e := output.ParseTemplate(topic)
+	if e != nil {
+		return nil, e
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(fmt.Sprintf("playback-%d", time.Now().UnixNano())).
+		SetConnectTimeout(to).
+		SetWriteTimeout(to).
+		SetOrderMatters(false)
+	return &MQTT{opts: opts, topic: t, codec: codec}, nil
+}
+
+// ClientID sets the client identifier. By default, a unique one is generated.
+func (m *MQTT) ClientID(id string) {
+	m.opts.SetClientID(id)
+}
+
+// Credentials sets the user name and password.
+func (m *MQTT) Credentials(user string, password string) {
+	m.opts.SetUsername(user)
+	m.opts.SetPassword(password)
+}
+
+// TLS sets the TLS configuration, used with "ssl://" brokers.
+func (m *MQTT) TLS(c *tls.Config) {
+	m.opts.SetTLSConfig(c)
+}
+
+// QoS sets the quality of service level of the messages: 0 - at most once,
+// 1 - at least once, 2 - exactly once.
+func (m *MQTT) QoS(q byte) error {
+	if q > 2 {
+		return fmt.Errorf("invalid qos %d", q)
+	}
+	m.qos = q
+	return nil
+}
+
+// Retained sets the retained flag of the messages, so that the broker keeps
+// the last message of every topic for new subscribers.
+func (m *MQTT) Retained(r bool) {
+	m.retained = r
+}
+
+// Connect connects to the broker.
+func (m *MQTT) Connect() error {
+	m.client = mqtt.NewClient(m.opts)
+	t := m.client.Connect()
+	t.Wait()
+	if e := t.Error(); e != nil {
+		return fmt.Errorf("error connecting to mqtt broker: %s", e)
+	}
+	return nil
+}
+
+// Publish sends the message and returns once it is sent with QoS 0, or
+// acknowledged by the broker with QoS 1 and 2.
+func (m *MQTT) Publish(ctx context.Context, msg *output.Message) error {
+	rec, e := output.Record(m.codec, msg.Data, m.topic)
+	if e != nil {
+		return fmt.Errorf("unable to decode record for topic template: %s", e)
+	}
+	topic, e := m.topic.Execute(rec)
+	if e != nil {
+		return e
+	}
+
+	t := m.client.Publish(topic, m.qos, m.retained, msg.Data)
+	select {
+	case <-t.Done():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if e := t.Error(); e != nil {
+		return e
+	}
+	log.Printf("Published message to %s (%s)", topic, msg.Tag)
+	return nil
+}
+
+// Close disconnects from the broker, waiting for the in-flight messages.
+func (m *MQTT) Close() error {
+	if m.client != nil {
+		m.client.Disconnect(250)
+	}
+	return nil
+}
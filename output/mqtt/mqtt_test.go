@@ -0,0 +1,76 @@
+package mqtt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang"
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+	"github.com/pburakov/playback/test"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTimeout = 5 * time.Second
+
+func TestPublish(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	broker := "tcp://" + test.BindContainer("eclipse-mosquitto:1.6", "", "1883")
+
+	got := make(chan mqtt.Message, 1)
+	c := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker).SetClientID("test"))
+	assert.True(t, c.Connect().WaitTimeout(testTimeout))
+	defer c.Disconnect(0)
+	assert.True(t, c.Subscribe("devices/#", 1, func(_ mqtt.Client, m mqtt.Message) {
+		got <- m
+	}).WaitTimeout(testTimeout))
+
+	s, e := Init(broker, "devices/{{device.id}}/telemetry", input.JSONCodec{}, testTimeout)
+	assert.NoError(t, e)
+	assert.NoError(t, s.QoS(1))
+	s.Retained(true)
+	assert.NoError(t, s.Connect())
+	defer s.Close()
+
+	e = s.Publish(context.Background(), &output.Message{Data: []byte(`{"device":{"id":"d1"},"temp":21.5}`)})
+	assert.NoError(t, e)
+
+	select {
+	case m := <-got:
+		assert.Equal(t, "devices/d1/telemetry", m.Topic())
+		assert.Equal(t, `{"device":{"id":"d1"},"temp":21.5}`, string(m.Payload()))
+	case <-time.After(testTimeout):
+		t.Error("message not received")
+	}
+
+	// the broker keeps the retained message for new subscriptions
+	late := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker).SetClientID("test-late"))
+	assert.True(t, late.Connect().WaitTimeout(testTimeout))
+	defer late.Disconnect(0)
+	assert.True(t, late.Subscribe("devices/d1/telemetry", 1, func(_ mqtt.Client, m mqtt.Message) {
+		got <- m
+	}).WaitTimeout(testTimeout))
+
+	select {
+	case m := <-got:
+		assert.True(t, m.Retained())
+		assert.Equal(t, `{"device":{"id":"d1"},"temp":21.5}`, string(m.Payload()))
+	case <-time.After(testTimeout):
+		t.Error("retained message not received")
+	}
+
+	e = s.Publish(context.Background(), &output.Message{Data: []byte(`{"temp":21.5}`)})
+	assert.Error(t, e)
+}
+
+func TestQoS(t *testing.T) {
+	s, e := Init("tcp://localhost:1883", "devices", nil, testTimeout)
+	assert.NoError(t, e)
+
+	assert.NoError(t, s.QoS(2))
+	assert.Error(t, s.QoS(3))
+	assert.Equal(t, byte(2), s.qos)
+}
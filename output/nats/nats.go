@@ -0,0 +1,116 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+)
+
+// NATS is a sink publishing messages to NATS subjects. Subjects can be derived
+// from the record fields. Messages are published either to core NATS, without
+// delivery confirmation, or to JetStream, waiting for the acknowledgement.
+type NATS struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject *output.Template
+	msgID   *output.Template
+	codec   input.Codec
+}
+
+var _ output.Sink = (*NATS)(nil)
+
+// Init connects to the NATS server at the given URL and returns a sink
+// publishing to core NATS. The subject is a template, which placeholders are
+// replaced with the record field values, e.g. "orders.{{region}}". The codec
+// is used to decode the records for templates, and can be nil if no templates
+// are used.
+func Init(url string, subject string, codec input.Codec, to time.Duration) (*NATS, error) {
+	t, e := output.ParseTemplate(subject)
+	if e != nil {
+		return nil, e
+	}
+	conn, e := nats.Connect(url, nats.Name("playback"), nats.Timeout(to))
+	if e != nil {
+		return nil, fmt.Errorf("error connecting to nats: %s", e)
+	}
+	return &NATS{conn: conn, subject: t, codec: codec}, nil
+}
+
+// JetStream enables publishing to JetStream with acknowledgements. Optional
+// message ID template is used to set the deduplication ID of the messages.
+func (n *NATS) JetStream(msgID string) error {
+	js, e := jetstream.New(n.conn)
+	if e != nil {
+		return e
+	}
+	if len(msgID) > 0 {
+		t, e := output.ParseTemplate(msgID)
+		if e != nil {
+			return e
+		}
+		n.msgID = t
+	}
+	n.js = js
+	return nil
+}
+
+// Publish sends the message. Message attributes are sent as headers. With
+// JetStream enabled, it returns once the message is acknowledged by the stream.
+func (n *NATS) Publish(ctx context.Context, m *output.Message) error {
+	rec, e := output.Record(n.codec, m.Data, n.subject, n.msgID)
+	if e != nil {
+		return fmt.Errorf("unable to decode record for templates: %s", e)
+	}
+	subj, e := n.subject.Execute(rec)
+	if e != nil {
+		return e
+	}
+
+	msg := nats.NewMsg(subj)
+	msg.Data = m.Data
+	for k, v := range m.Attributes {
+		msg.Header.Set(k, v)
+	}
+
+	if n.js == nil {
+		if e := n.conn.PublishMsg(msg); e != nil {
+			return e
+		}
+		log.Printf("Published message to %s (%s)", subj, m.Tag)
+		return nil
+	}
+
+	var opts []jetstream.PublishOpt
+	if n.msgID != nil {
+		id, e := n.msgID.Execute(rec)
+		if e != nil {
+			return e
+		}
+		opts = append(opts, jetstream.WithMsgID(id))
+	}
+	ack, e := n.js.PublishMsg(ctx, msg, opts...)
+	if e != nil {
+		return e
+	}
+	if ack.Duplicate {
+		log.Printf("Duplicate message in stream %s (%s)", ack.Stream, m.Tag)
+		return nil
+	}
+	log.Printf("Published message seq %d to stream %s (%s)", ack.Sequence, ack.Stream, m.Tag)
+	return nil
+}
+
+// Close flushes pending messages and closes the connection.
+func (n *NATS) Close() error {
+	e := n.conn.Drain()
+	if e == nats.ErrConnectionClosed {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,78 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+	"github.com/pburakov/playback/test"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTimeout = 5 * time.Second
+
+func TestPublish(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	url := "nats://" + test.BindContainer("nats:latest", "-js", "4222")
+
+	conn, e := nats.Connect(url)
+	assert.NoError(t, e)
+	defer conn.Close()
+	sub, e := conn.SubscribeSync("orders.>")
+	assert.NoError(t, e)
+
+	s, e := Init(url, "orders.{{region}}", input.JSONCodec{}, testTimeout)
+	assert.NoError(t, e)
+	defer s.Close()
+
+	e = s.Publish(context.Background(), &output.Message{
+		Data:       []byte(`{"region":"eu","id":1}`),
+		Attributes: map[string]string{"orig": "foo"},
+	})
+	assert.NoError(t, e)
+
+	m, e := sub.NextMsg(testTimeout)
+	assert.NoError(t, e)
+	assert.Equal(t, "orders.eu", m.Subject)
+	assert.Equal(t, `{"region":"eu","id":1}`, string(m.Data))
+	assert.Equal(t, "foo", m.Header.Get("orig"))
+
+	e = s.Publish(context.Background(), &output.Message{Data: []byte(`{"id":1}`)})
+	assert.Error(t, e)
+}
+
+func TestPublishJetStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	url := "nats://" + test.BindContainer("nats:latest", "-js", "4222")
+
+	conn, e := nats.Connect(url)
+	assert.NoError(t, e)
+	defer conn.Close()
+	js, e := jetstream.New(conn)
+	assert.NoError(t, e)
+	stream, e := js.CreateStream(context.Background(), jetstream.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.*"}})
+	assert.NoError(t, e)
+
+	s, e := Init(url, "orders.created", input.JSONCodec{}, testTimeout)
+	assert.NoError(t, e)
+	defer s.Close()
+	assert.NoError(t, s.JetStream("order-{{id}}"))
+
+	for i := 0; i < 2; i++ {
+		e = s.Publish(context.Background(), &output.Message{Data: []byte(`{"id":1}`)})
+		assert.NoError(t, e)
+	}
+
+	info, e := stream.Info(context.Background())
+	assert.NoError(t, e)
+	// the second message is deduplicated
+	assert.Equal(t, uint64(1), info.State.Msgs)
+}
@@ -2,22 +2,156 @@ package output
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
 	"cloud.google.com/go/pubsub"
+	"golang.org/x/sync/semaphore"
 )
 
-// Publish handles PubSub publishing procedure synchronously. Errors are ignored and logged.
-func Publish(t *pubsub.Topic, tag string, d []byte, to time.Duration) {
-	ctx, cancel := context.WithTimeout(context.Background(), to)
-	defer cancel()
+// PubSub is a sink publishing messages to a PubSub topic. Messages are
+// published asynchronously, so that the client library can batch them
+// according to the PublishSettings of the topic. The number and the size of
+// the outstanding messages can be limited.
+type PubSub struct {
+	t        *pubsub.Topic
+	limit    chan struct{}
+	bytes    *semaphore.Weighted
+	maxBytes int64
+}
+
+var _ AsyncSink = (*PubSub)(nil)
+
+// InitPubSub returns a sink publishing to the given topic. The timeout is set
+// as the publish timeout of the topic, limiting the time spent publishing every
+// batch of messages.
+func InitPubSub(t *pubsub.Topic, to time.Duration) *PubSub {
+	t.PublishSettings.Timeout = to
+	return &PubSub{t: t}
+}
+
+// Limit sets the maximum number and the total size in bytes of the messages
+// published but not yet confirmed, 0 - unlimited. Publishing blocks while the
+// limits are exceeded.
+func (p *PubSub) Limit(messages int, bytes int) {
+	p.limit = nil
+	if messages > 0 {
+		p.limit = make(chan struct{}, messages)
+	}
+	p.bytes, p.maxBytes = nil, int64(bytes)
+	if bytes > 0 {
+		p.bytes = semaphore.NewWeighted(p.maxBytes)
+	}
+}
 
-	res := t.Publish(ctx, &pubsub.Message{Data: d})
-	id, e := res.Get(ctx)
+// Publish sends the message and returns once it is confirmed or failed.
+func (p *PubSub) Publish(ctx context.Context, m *Message) error {
+	c := make(chan error, 1)
+	p.PublishAsync(ctx, m, func(e error) {
+		c <- e
+	})
+	return <-c
+}
+
+// PublishAsync adds the message to the current batch and returns, once the
+// outstanding message limits allow it. The callback is called once the batch
+// holding the message is published.
+func (p *PubSub) PublishAsync(ctx context.Context, m *Message, done func(error)) {
+	release, e := p.acquire(ctx, len(m.Data))
 	if e != nil {
-		log.Printf("Error publishing message: %s", e)
+		done(e)
 		return
 	}
-	log.Printf("Published message id %s (%s)", id, tag)
+	res := p.t.Publish(ctx, &pubsub.Message{Data: m.Data, Attributes: m.Attributes})
+	go func() {
+		<-res.Ready()
+		release()
+		id, e := res.Get(context.Background())
+		if e == nil {
+			log.Printf("Published message id %s (%s)", id, m.Tag)
+		}
+		done(e)
+	}()
+}
+
+// acquire waits until the message of the given size fits into the outstanding
+// message limits, and returns the function releasing it.
+func (p *PubSub) acquire(ctx context.Context, size int) (func(), error) {
+	limit, bytes := p.limit, p.bytes
+	if limit != nil {
+		select {
+		case limit <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	// messages larger than the limit are sent alone
+	n := int64(size)
+	if n > p.maxBytes {
+		n = p.maxBytes
+	}
+	if bytes != nil {
+		if e := bytes.Acquire(ctx, n); e != nil {
+			if limit != nil {
+				<-limit
+			}
+			return nil, e
+		}
+	}
+	return func() {
+		if bytes != nil {
+			bytes.Release(n)
+		}
+		if limit != nil {
+			<-limit
+		}
+	}, nil
+}
+
+// InitTopic returns the topic with the given name. A missing topic is created
+// if create is set, and reported as an error otherwise. Existing topics are
+// left as is.
+func InitTopic(ctx context.Context, c *pubsub.Client, name string, create bool) (*pubsub.Topic, error) {
+	t := c.Topic(name)
+	b, e := t.Exists(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("unexpected pubsub error: %s", e)
+	}
+	if b {
+		return t, nil
+	}
+	if !create {
+		return nil, fmt.Errorf("topic %q does not exist, enable create_topic to create it", name)
+	}
+	if t, e = c.CreateTopic(ctx, name); e != nil {
+		return nil, fmt.Errorf("error creating topic %q: %s", name, e)
+	}
+	log.Printf("Created PubSub topic %s", name)
+	return t, nil
+}
+
+// InitSubscription returns the subscription with the given name, creating it
+// for the topic if it is missing. Existing subscriptions are left as is.
+func InitSubscription(ctx context.Context, c *pubsub.Client, t *pubsub.Topic, name string) (*pubsub.Subscription, error) {
+	s := c.Subscription(name)
+	b, e := s.Exists(ctx)
+	if e != nil {
+		return nil, fmt.Errorf("unexpected pubsub error: %s", e)
+	}
+	if b {
+		return s, nil
+	}
+	if s, e = c.CreateSubscription(ctx, name, pubsub.SubscriptionConfig{Topic: t}); e != nil {
+		return nil, fmt.Errorf("error creating subscription %q: %s", name, e)
+	}
+	log.Printf("Created PubSub subscription %s", name)
+	return s, nil
+}
+
+// Close publishes the remaining messages and stops the publishing goroutines
+// of the topic.
+func (p *PubSub) Close() error {
+	p.t.Stop()
+	return nil
 }
@@ -23,10 +23,85 @@ func TestPublish(t *testing.T) {
 	success := make(chan bool, 1)
 	go subscribe(t, sub, "foobar", success)
 
-	Publish(topic, "baz", []byte("foobar"), testTimeout)
+	e := InitPubSub(topic, testTimeout).Publish(context.Background(), &Message{Tag: "baz", Data: []byte("foobar")})
+	assert.NoError(t, e)
 	waitForSuccess(t, success)
 }
 
+func TestInitTopic(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	ps := test.BindPubSub().PubSubClient
+	ctx := context.Background()
+
+	_, e := InitTopic(ctx, ps, "init-topic", false)
+	assert.Error(t, e)
+
+	topic, e := InitTopic(ctx, ps, "init-topic", true)
+	assert.NoError(t, e)
+	b, e := topic.Exists(ctx)
+	assert.NoError(t, e)
+	assert.True(t, b)
+
+	sub, e := InitSubscription(ctx, ps, topic, "init-sub")
+	assert.NoError(t, e)
+	cfg, e := sub.Config(ctx)
+	assert.NoError(t, e)
+	assert.Equal(t, topic.ID(), cfg.Topic.ID())
+
+	// existing topics and subscriptions are left as is
+	existing, e := ps.CreateSubscription(ctx, "existing-sub", pubsub.SubscriptionConfig{Topic: topic, AckDeadline: 42 * time.Second})
+	assert.NoError(t, e)
+	_, e = InitTopic(ctx, ps, "init-topic", true)
+	assert.NoError(t, e)
+	_, e = InitSubscription(ctx, ps, topic, "existing-sub")
+	assert.NoError(t, e)
+	cfg, e = existing.Config(ctx)
+	assert.NoError(t, e)
+	assert.Equal(t, 42*time.Second, cfg.AckDeadline)
+	assert.Equal(t, topic.ID(), cfg.Topic.ID())
+}
+
+func TestLimit(t *testing.T) {
+	p := new(PubSub)
+	p.Limit(2, 100)
+	// try acquires the message size, failing if it has to wait
+	try := func(size int) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		release, e := p.acquire(ctx, size)
+		if e == nil {
+			release()
+		}
+		return e
+	}
+
+	r1, e := p.acquire(context.Background(), 60)
+	assert.NoError(t, e)
+	// the size of messages is limited
+	assert.Equal(t, context.DeadlineExceeded, try(50))
+	// a message larger than the limit waits for all others
+	assert.Equal(t, context.DeadlineExceeded, try(1000))
+	assert.NoError(t, try(40))
+
+	r1()
+	assert.NoError(t, try(1000))
+
+	r1, _ = p.acquire(context.Background(), 1)
+	r2, _ := p.acquire(context.Background(), 1)
+	// the number of messages is limited
+	assert.Equal(t, context.DeadlineExceeded, try(1))
+	r1()
+	r2()
+
+	p.Limit(0, 0)
+	for i := 0; i < 10; i++ {
+		_, e := p.acquire(context.Background(), 1000)
+		assert.NoError(t, e)
+	}
+}
+
 func setup(t *testing.T) (*pubsub.Topic, *pubsub.Subscription) {
 	ps := test.BindPubSub().PubSubClient
 
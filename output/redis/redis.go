@@ -0,0 +1,228 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+	"github.com/redis/go-redis/v9"
+)
+
+// PayloadField is the default stream entry field holding the whole payload.
+const PayloadField = "data"
+
+// AllFields maps every top-level field of the record to a stream entry field.
+const AllFields = "*"
+
+// Redis is a sink adding messages to Redis streams with XADD. The stream
+// entry either holds the whole payload in a single field, or the top-level
+// fields of the record. Message attributes are added as entry fields.
+// Streams can be trimmed with MAXLEN, and commands can be pipelined for
+// throughput.
+type Redis struct {
+	client  *redis.Client
+	stream  *output.Template
+	codec   input.Codec
+	payload string
+	fields  []string
+
+	maxLen int64
+	approx bool
+
+	pipe *output.Batcher[*redis.XAddArgs, redis.Cmder]
+}
+
+var _ output.Sink = (*Redis)(nil)
+
+// Init connects to the Redis server at the given URL, e.g.
+// "redis://localhost:6379/0", and returns a sink adding the whole payloads to
+// the given stream. The stream name is a template, which placeholders are
+// replaced with the record field values, e.g. "orders:{{region}}". The codec
+// is used to decode the records for templates and field mapping, and can be
+// nil if neither is used.
+func Init(url string, stream string, codec input.Codec, to time.Duration) (*Redis, error) {
+	t, e := output.ParseTemplate(stream)
+	if e != nil {
+		return nil, e
+	}
+	opts, e := redis.ParseURL(url)
+	if e != nil {
+		return nil, fmt.Errorf("invalid redis url: %s", e)
+	}
+	opts.DialTimeout = to
+	opts.ReadTimeout = to
+	opts.WriteTimeout = to
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), to)
+	defer cancel()
+	if e := client.Ping(ctx).Err(); e != nil {
+		client.Close()
+		return nil, fmt.Errorf("error connecting to redis: %s", e)
+	}
+	return &Redis{client: client, stream: t, codec: codec, payload: PayloadField}, nil
+}
+
+// Payload sets the entry field holding the whole payload.
+func (r *Redis) Payload(field string) {
+	r.payload = field
+}
+
+// Fields maps the given top-level record fields to the stream entry fields,
+// instead of adding the whole payload. AllFields maps every field. Missing
+// fields are omitted. String values are added as is, other values as JSON.
+func (r *Redis) Fields(names ...string) {
+	r.fields = names
+}
+
+// MaxLen trims the streams to the given length on every XADD, 0 - no
+// trimming. Approximate trimming (MAXLEN ~) is more efficient and keeps at
+// least the given number of entries.
+func (r *Redis) MaxLen(n int64, approx bool) {
+	r.maxLen = n
+	r.approx = approx
+}
+
+// Pipeline enables sending the commands in pipelines of up to the given size.
+// A pipeline is sent once it is full, or once the given delay passes since the
+// first command was added.
+func (r *Redis) Pipeline(size int, delay time.Duration) {
+	if size > 1 {
+		r.pipe = output.InitBatcher(size, delay, r.exec)
+	} else {
+		r.pipe = nil
+	}
+}
+
+// Publish adds the message to the stream and returns once the command
+// succeeds or fails. With pipelining enabled, it returns once the pipeline
+// holding the command is sent.
+func (r *Redis) Publish(ctx context.Context, m *output.Message) error {
+	a, e := r.args(m)
+	if e != nil {
+		return e
+	}
+	if r.pipe == nil {
+		id, e := r.client.XAdd(ctx, a).Result()
+		if e != nil {
+			return e
+		}
+		log.Printf("Added entry %s to stream %s (%s)", id, a.Stream, m.Tag)
+		return nil
+	}
+
+	cmd, n, e := r.pipe.Add(ctx, a)
+	if e != nil {
+		return e
+	}
+	id, e := cmd.(*redis.StringCmd).Result()
+	if e != nil {
+		return e
+	}
+	log.Printf("Added entry %s to stream %s in a pipeline of %d (%s)", id, a.Stream, n, m.Tag)
+	return nil
+}
+
+// exec sends the commands in a pipeline. Commands fail individually, unless
+// the pipeline fails as a whole.
+func (r *Redis) exec(ctx context.Context, args []*redis.XAddArgs) ([]redis.Cmder, error) {
+	cmds, e := r.client.Pipelined(ctx, func(pl redis.Pipeliner) error {
+		for _, a := range args {
+			pl.XAdd(ctx, a)
+		}
+		return nil
+	})
+	if len(cmds) < len(args) {
+		return nil, e
+	}
+	return cmds, nil
+}
+
+// args returns the XADD arguments for the message.
+func (r *Redis) args(m *output.Message) (*redis.XAddArgs, error) {
+	var rec map[string]interface{}
+	var e error
+	if len(r.fields) > 0 {
+		if r.codec == nil {
+			return nil, fmt.Errorf("field mapping isn't supported for the input format")
+		}
+		rec, e = r.codec.Decode(m.Data)
+	} else {
+		rec, e = output.Record(r.codec, m.Data, r.stream)
+	}
+	if e != nil {
+		return nil, fmt.Errorf("unable to decode record: %s", e)
+	}
+	s, e := r.stream.Execute(rec)
+	if e != nil {
+		return nil, e
+	}
+
+	var values []interface{}
+	if len(r.fields) > 0 {
+		values, e = fieldValues(rec, r.fields)
+		if e != nil {
+			return nil, e
+		}
+	} else {
+		values = []interface{}{r.payload, m.Data}
+	}
+	for _, k := range sortedKeys(m.Attributes) {
+		values = append(values, k, m.Attributes[k])
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no fields to add to stream %s", s)
+	}
+
+	return &redis.XAddArgs{
+		Stream: s,
+		MaxLen: r.maxLen,
+		Approx: r.approx && r.maxLen > 0,
+		Values: values,
+	}, nil
+}
+
+// fieldValues returns the field-value pairs of the named top-level record
+// fields, or of all fields if AllFields is given. Missing fields are omitted.
+func fieldValues(rec map[string]interface{}, names []string) ([]interface{}, error) {
+	if len(names) == 1 && names[0] == AllFields {
+		names = make([]string, 0, len(rec))
+		for k := range rec {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+	}
+	values := make([]interface{}, 0, 2*len(names))
+	for _, k := range names {
+		v, found := rec[k]
+		if !found {
+			continue
+		}
+		s, e := output.FormatValue(v)
+		if e != nil {
+			return nil, e
+		}
+		values = append(values, k, s)
+	}
+	return values, nil
+}
+
+// sortedKeys returns the keys of the map in order, so that the entry fields
+// are stable.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Close closes the connection.
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
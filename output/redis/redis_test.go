@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+	"github.com/pburakov/playback/test"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTimeout = 5 * time.Second
+
+func TestPublish(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	url := "redis://" + test.BindContainer("redis:latest", "", "6379")
+
+	s, e := Init(url, "orders:{{region}}", input.JSONCodec{}, testTimeout)
+	assert.NoError(t, e)
+	defer s.Close()
+	s.MaxLen(1, false)
+
+	for i := 0; i < 2; i++ {
+		e = s.Publish(context.Background(), &output.Message{
+			Data:       []byte(`{"region":"eu","id":1}`),
+			Attributes: map[string]string{"orig": "foo"},
+		})
+		assert.NoError(t, e)
+	}
+
+	opts, _ := redis.ParseURL(url)
+	c := redis.NewClient(opts)
+	defer c.Close()
+	l, e := c.XRange(context.Background(), "orders:eu", "-", "+").Result()
+	assert.NoError(t, e)
+	// the stream is trimmed to a single entry
+	assert.Len(t, l, 1)
+	assert.Equal(t, map[string]interface{}{"data": `{"region":"eu","id":1}`, "orig": "foo"}, l[0].Values)
+}
+
+func TestPublishPipeline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	url := "redis://" + test.BindContainer("redis:latest", "", "6379")
+
+	s, e := Init(url, "orders", input.JSONCodec{}, testTimeout)
+	assert.NoError(t, e)
+	defer s.Close()
+	s.Fields(AllFields)
+	s.Pipeline(2, 10*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for _, d := range []string{`{"id":1}`, `{"id":2}`, `{"id":3}`} {
+		wg.Add(1)
+		go func(d string) {
+			assert.NoError(t, s.Publish(context.Background(), &output.Message{Data: []byte(d)}))
+			wg.Done()
+		}(d)
+	}
+	wg.Wait()
+
+	opts, _ := redis.ParseURL(url)
+	c := redis.NewClient(opts)
+	defer c.Close()
+	n, e := c.XLen(context.Background(), "orders").Result()
+	assert.NoError(t, e)
+	assert.Equal(t, int64(3), n)
+}
+
+func TestArgs(t *testing.T) {
+	st, _ := output.ParseTemplate("orders:{{region}}")
+	r := &Redis{stream: st, codec: input.JSONCodec{}, payload: PayloadField}
+	m := &output.Message{
+		Data:       []byte(`{"region":"eu","id":1,"user":{"id":42}}`),
+		Attributes: map[string]string{"b": "2", "a": "1"},
+	}
+
+	a, e := r.args(m)
+
+	assert.NoError(t, e)
+	assert.Equal(t, "orders:eu", a.Stream)
+	assert.Equal(t, []interface{}{"data", m.Data, "a", "1", "b", "2"}, a.Values)
+	assert.False(t, a.Approx)
+
+	r.Fields("user", "id", "missing")
+	r.MaxLen(100, true)
+
+	a, e = r.args(m)
+
+	assert.NoError(t, e)
+	assert.Equal(t, []interface{}{"user", `{"id":42}`, "id", "1", "a", "1", "b", "2"}, a.Values)
+	assert.Equal(t, int64(100), a.MaxLen)
+	assert.True(t, a.Approx)
+
+	r.Fields(AllFields)
+
+	a, e = r.args(&output.Message{Data: m.Data})
+
+	assert.NoError(t, e)
+	assert.Equal(t, []interface{}{"id", "1", "region", "eu", "user", `{"id":42}`}, a.Values)
+
+	_, e = r.args(&output.Message{Data: []byte(`{"id":1}`)})
+
+	assert.Error(t, e)
+}
@@ -0,0 +1,47 @@
+package output
+
+import (
+	"context"
+	"time"
+)
+
+// Message is a single record sent to a sink.
+type Message struct {
+	// Tag describes the message origin for logging, e.g. "no=42".
+	Tag string
+	// Data is the message payload.
+	Data []byte
+	// Attributes are optional message attributes, can be nil.
+	Attributes map[string]string
+	// Timestamp is the send time, set by the player from its clock: the
+	// intended send time in simulated mode, and the publishing time otherwise.
+	// Zero if unknown.
+	Timestamp time.Time
+}
+
+// Sink publishes messages to an output destination.
+type Sink interface {
+	// Publish sends the message and returns once the delivery is confirmed or
+	// failed. Implementations must be safe for concurrent use.
+	Publish(ctx context.Context, m *Message) error
+}
+
+// AsyncSink is a sink which can publish messages without waiting for the
+// delivery, e.g. to let the client library batch them. Players prefer
+// PublishAsync over Publish for sinks implementing it.
+type AsyncSink interface {
+	Sink
+	// PublishAsync hands the message over for sending and returns, possibly
+	// blocking for flow control. The callback is called once the delivery is
+	// confirmed or failed.
+	PublishAsync(ctx context.Context, m *Message, done func(error))
+}
+
+// SinkFunc is an adapter allowing to use an ordinary function as a sink.
+type SinkFunc func(ctx context.Context, m *Message) error
+
+var _ Sink = SinkFunc(nil)
+
+func (f SinkFunc) Publish(ctx context.Context, m *Message) error {
+	return f(ctx, m)
+}
@@ -0,0 +1,239 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+)
+
+// MaxBatch is the maximum number of messages sent in a single batch request.
+const MaxBatch = 10
+
+// MaxBatchBytes is the maximum total payload size of a single batch request,
+// counting the message bodies and attributes.
+const MaxBatchBytes = 256 * 1024
+
+// localRegion is used with custom endpoints if no region is configured, since
+// local emulators ignore it.
+const localRegion = "us-east-1"
+
+// SQS is a sink sending messages to an Amazon SQS queue, or to a queue of a
+// compatible service, such as ElasticMQ. Messages can be sent in batches.
+// Message group and deduplication IDs of FIFO queues can be derived from the
+// record fields.
+type SQS struct {
+	client   *sqs.Client
+	queueURL string
+	codec    input.Codec
+	groupID  *output.Template
+	dedupID  *output.Template
+	batcher  *output.Batcher[types.SendMessageBatchRequestEntry, error]
+}
+
+var _ output.Sink = (*SQS)(nil)
+
+// Init returns a sink sending messages to the queue at the given URL. The
+// region and credentials are read from the standard AWS configuration, unless
+// the region is given. The endpoint overrides the SQS endpoint, e.g. with the
+// one of a local emulator, and can be empty. The codec is used to decode the
+// records which aren't JSON, and for templates. It can be nil if neither is
+// needed.
+func Init(queueURL string, region string, endpoint string, codec input.Codec, to time.Duration) (*SQS, error) {
+	opts := []func(*config.LoadOptions) error{
+		config.WithHTTPClient(awshttp.NewBuildableClient().WithTimeout(to)),
+	}
+	if len(region) > 0 {
+		opts = append(opts, config.WithRegion(region))
+	}
+	cfg, e := config.LoadDefaultConfig(context.Background(), opts...)
+	if e != nil {
+		return nil, fmt.Errorf("error loading aws config: %s", e)
+	}
+	if len(cfg.Region) == 0 {
+		if len(endpoint) == 0 {
+			return nil, errors.New("aws region is not configured")
+		}
+		cfg.Region = localRegion
+	}
+	client := sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if len(endpoint) > 0 {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	return &SQS{client: client, queueURL: queueURL, codec: codec}, nil
+}
+
+// GroupID sets the message group ID, required for FIFO queues. The value is
+// a template, which placeholders are replaced with the record field values,
+// e.g. "{{user.id}}".
+func (s *SQS) GroupID(tmpl string) error {
+	t, e := output.ParseTemplate(tmpl)
+	if e != nil {
+		return e
+	}
+	s.groupID = t
+	return nil
+}
+
+// DeduplicationID sets the message deduplication ID of FIFO queues, unless
+// content-based deduplication is enabled for the queue. The value is a
+// template, e.g. "{{order_id}}".
+func (s *SQS) DeduplicationID(tmpl string) error {
+	t, e := output.ParseTemplate(tmpl)
+	if e != nil {
+		return e
+	}
+	s.dedupID = t
+	return nil
+}
+
+// Batch enables sending the messages in batches of up to the given size, at
+// most MaxBatch. A batch is sent once it is full, or once the given delay
+// passes since the first message was added. A batch is sent early if the next
+// message would take it over MaxBatchBytes.
+func (s *SQS) Batch(size int, delay time.Duration) error {
+	if size > MaxBatch {
+		return fmt.Errorf("batch size %d exceeds maximum of %d", size, MaxBatch)
+	}
+	if size > 1 {
+		s.batcher = output.InitBatcher(size, delay, s.send)
+		s.batcher.Limit(MaxBatchBytes, entrySize)
+	} else {
+		s.batcher = nil
+	}
+	return nil
+}
+
+// Publish sends the message and returns once the request succeeds or fails.
+// With batching enabled, it returns once the batch holding the message is sent.
+// Message attributes are sent as string message attributes.
+func (s *SQS) Publish(ctx context.Context, m *output.Message) error {
+	en, e := s.entry(m)
+	if e != nil {
+		return e
+	}
+
+	if s.batcher == nil {
+		out, e := s.client.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:               aws.String(s.queueURL),
+			MessageBody:            en.MessageBody,
+			MessageGroupId:         en.MessageGroupId,
+			MessageDeduplicationId: en.MessageDeduplicationId,
+			MessageAttributes:      en.MessageAttributes,
+		})
+		if e != nil {
+			return e
+		}
+		log.Printf("Sent message %s (%s)", aws.ToString(out.MessageId), m.Tag)
+		return nil
+	}
+
+	failed, n, e := s.batcher.Add(ctx, en)
+	if e != nil {
+		return e
+	}
+	if failed != nil {
+		return failed
+	}
+	log.Printf("Sent message in a batch of %d (%s)", n, m.Tag)
+	return nil
+}
+
+// send sends the entries in a batch request, identified by their indexes.
+// Messages of a batch can fail individually.
+func (s *SQS) send(ctx context.Context, entries []types.SendMessageBatchRequestEntry) ([]error, error) {
+	for i := range entries {
+		entries[i].Id = aws.String(strconv.Itoa(i))
+	}
+	out, e := s.client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(s.queueURL),
+		Entries:  entries,
+	})
+	if e != nil {
+		return nil, e
+	}
+	failed := make([]error, len(entries))
+	for _, f := range out.Failed {
+		i, e := strconv.Atoi(aws.ToString(f.Id))
+		if e != nil || i < 0 || i >= len(entries) {
+			continue
+		}
+		failed[i] = fmt.Errorf("message was rejected: %s: %s", aws.ToString(f.Code), aws.ToString(f.Message))
+	}
+	return failed, nil
+}
+
+// entrySize returns the payload size of the entry, counted towards the batch
+// size limit.
+func entrySize(en types.SendMessageBatchRequestEntry) int {
+	n := len(aws.ToString(en.MessageBody))
+	for k, v := range en.MessageAttributes {
+		n += len(k) + len(aws.ToString(v.DataType)) + len(aws.ToString(v.StringValue))
+	}
+	return n
+}
+
+// entry returns the batch entry for the message, without the ID. Message
+// bodies must be text, so records which aren't JSON are decoded with the
+// codec and converted to JSON.
+func (s *SQS) entry(m *output.Message) (types.SendMessageBatchRequestEntry, error) {
+	var en types.SendMessageBatchRequestEntry
+	rec, e := output.Record(s.codec, m.Data, s.groupID, s.dedupID)
+	if e != nil {
+		return en, fmt.Errorf("unable to decode record for templates: %s", e)
+	}
+
+	d := m.Data
+	if !json.Valid(d) && s.codec != nil {
+		if rec == nil {
+			if rec, e = s.codec.Decode(d); e != nil {
+				return en, fmt.Errorf("unable to decode record: %s", e)
+			}
+		}
+		if d, e = json.Marshal(rec); e != nil {
+			return en, e
+		}
+	}
+	if !utf8.Valid(d) {
+		return en, errors.New("message body must be text")
+	}
+	en.MessageBody = aws.String(string(d))
+
+	if s.groupID != nil {
+		v, e := s.groupID.Execute(rec)
+		if e != nil {
+			return en, e
+		}
+		en.MessageGroupId = aws.String(v)
+	}
+	if s.dedupID != nil {
+		v, e := s.dedupID.Execute(rec)
+		if e != nil {
+			return en, e
+		}
+		en.MessageDeduplicationId = aws.String(v)
+	}
+	if len(m.Attributes) > 0 {
+		en.MessageAttributes = make(map[string]types.MessageAttributeValue, len(m.Attributes))
+		for k, v := range m.Attributes {
+			en.MessageAttributes[k] = types.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(v),
+			}
+		}
+	}
+	return en, nil
+}
@@ -0,0 +1,208 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+	"github.com/pburakov/playback/test"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTimeout = 5 * time.Second
+
+func init() {
+	// local endpoints accept any credentials
+	os.Setenv("AWS_ACCESS_KEY_ID", "test")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+}
+
+// entry is a message received by the fake SQS endpoint.
+type entry struct {
+	Id                     string
+	MessageBody            string
+	MessageGroupId         string
+	MessageDeduplicationId string
+	MessageAttributes      map[string]map[string]string
+}
+
+// fakeSQS serves the SendMessage and SendMessageBatch actions of the SQS
+// JSON protocol, rejecting the messages with "reject" bodies.
+type fakeSQS struct {
+	mu      sync.Mutex
+	batches [][]entry
+}
+
+func (f *fakeSQS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		entry
+		Entries []entry
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if r.Header.Get("X-Amz-Target") == "AmazonSQS.SendMessage" {
+		req.Entries = []entry{req.entry}
+	}
+	f.mu.Lock()
+	f.batches = append(f.batches, req.Entries)
+	f.mu.Unlock()
+
+	var res struct {
+		MessageId  string                   `json:",omitempty"`
+		Successful []map[string]string      `json:",omitempty"`
+		Failed     []map[string]interface{} `json:",omitempty"`
+	}
+	res.MessageId = "m0"
+	for _, en := range req.Entries {
+		if en.MessageBody == `"reject"` {
+			res.Failed = append(res.Failed, map[string]interface{}{"Id": en.Id, "Code": "InvalidMessageContents", "SenderFault": true})
+		} else {
+			res.Successful = append(res.Successful, map[string]string{"Id": en.Id, "MessageId": "m" + en.Id})
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	json.NewEncoder(w).Encode(&res)
+}
+
+func TestPublish(t *testing.T) {
+	f := new(fakeSQS)
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	s, e := Init(srv.URL+"/000000000000/orders.fifo", "", srv.URL, input.JSONCodec{}, testTimeout)
+	assert.NoError(t, e)
+	assert.NoError(t, s.GroupID("{{user.id}}"))
+	assert.NoError(t, s.DeduplicationID("order-{{id}}"))
+
+	e = s.Publish(context.Background(), &output.Message{
+		Data:       []byte(`{"id":1,"user":{"id":42}}`),
+		Attributes: map[string]string{"orig": "foo"},
+	})
+
+	assert.NoError(t, e)
+	assert.Equal(t, [][]entry{{{
+		MessageBody:            `{"id":1,"user":{"id":42}}`,
+		MessageGroupId:         "42",
+		MessageDeduplicationId: "order-1",
+		MessageAttributes:      map[string]map[string]string{"orig": {"DataType": "String", "StringValue": "foo"}},
+	}}}, f.batches)
+
+	e = s.Publish(context.Background(), &output.Message{Data: []byte(`{"id":1}`)})
+
+	assert.Error(t, e)
+}
+
+func TestBatch(t *testing.T) {
+	f := new(fakeSQS)
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	s, e := Init(srv.URL+"/000000000000/orders", "", srv.URL, nil, testTimeout)
+	assert.NoError(t, e)
+	assert.Error(t, s.Batch(MaxBatch+1, time.Hour))
+	assert.NoError(t, s.Batch(3, time.Hour))
+
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	for _, d := range []string{`{"id":1}`, `"reject"`, `{"id":3}`} {
+		wg.Add(1)
+		go func(d string) {
+			e := s.Publish(context.Background(), &output.Message{Data: []byte(d)})
+			mu.Lock()
+			errs[d] = e
+			mu.Unlock()
+			wg.Done()
+		}(d)
+	}
+	wg.Wait()
+
+	assert.Len(t, f.batches, 1)
+	assert.Len(t, f.batches[0], 3)
+	assert.NoError(t, errs[`{"id":1}`])
+	assert.NoError(t, errs[`{"id":3}`])
+	// messages of a batch fail individually
+	assert.Error(t, errs[`"reject"`])
+
+	// an incomplete batch is sent after the delay
+	assert.NoError(t, s.Batch(3, 10*time.Millisecond))
+	assert.NoError(t, s.Publish(context.Background(), &output.Message{Data: []byte(`{"id":4}`)}))
+
+	assert.Len(t, f.batches, 2)
+	assert.Equal(t, "0", f.batches[1][0].Id)
+}
+
+func TestBatchBytes(t *testing.T) {
+	f := new(fakeSQS)
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	s, e := Init(srv.URL+"/000000000000/orders", "", srv.URL, nil, testTimeout)
+	assert.NoError(t, e)
+	assert.NoError(t, s.Batch(3, 10*time.Millisecond))
+
+	// two messages don't fit in a single batch request
+	d := `"` + strings.Repeat("a", MaxBatchBytes/2) + `"`
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			assert.NoError(t, s.Publish(context.Background(), &output.Message{Data: []byte(d)}))
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, f.batches, 2)
+	assert.Len(t, f.batches[0], 1)
+	assert.Len(t, f.batches[1], 1)
+}
+
+func TestEntry(t *testing.T) {
+	s := &SQS{codec: input.JSONCodec{}}
+
+	_, e := s.entry(&output.Message{Data: []byte("\xff")})
+
+	assert.Error(t, e)
+
+	s.codec = nil
+	en, e := s.entry(&output.Message{Data: []byte("plain text")})
+
+	assert.NoError(t, e)
+	assert.Equal(t, "plain text", aws.ToString(en.MessageBody))
+	assert.Nil(t, en.MessageGroupId)
+	assert.Nil(t, en.MessageAttributes)
+}
+
+func TestPublishElasticMQ(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	endpoint := "http://" + test.BindContainer("softwaremill/elasticmq-native:latest", "", "9324")
+
+	s, e := Init(endpoint+"/000000000000/orders", "", endpoint, input.JSONCodec{}, testTimeout)
+	assert.NoError(t, e)
+	_, e = s.client.CreateQueue(context.Background(), &sqs.CreateQueueInput{QueueName: aws.String("orders")})
+	assert.NoError(t, e)
+	assert.NoError(t, s.Batch(MaxBatch, 10*time.Millisecond))
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, s.Publish(context.Background(), &output.Message{Data: []byte(`{"id":1}`)}))
+	}
+
+	out, e := s.client.ReceiveMessage(context.Background(), &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(endpoint + "/000000000000/orders"),
+		MaxNumberOfMessages: 10,
+	})
+	assert.NoError(t, e)
+	assert.Len(t, out.Messages, 2)
+}
@@ -0,0 +1,95 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pburakov/playback/input"
+)
+
+var placeholder = regexp.MustCompile(`\{\{\s*([^\s}]+)\s*\}\}`)
+
+// Template is a string with placeholders in double curly braces, which are
+// replaced with the values of the record fields at the given paths, e.g.
+// "devices/{{device.id}}/telemetry". Sinks use templates for destination
+// names and headers derived from the records.
+type Template struct {
+	text  []string
+	paths []string
+}
+
+// ParseTemplate parses the template string.
+func ParseTemplate(s string) (*Template, error) {
+	t := new(Template)
+	last := 0
+	for _, m := range placeholder.FindAllStringSubmatchIndex(s, -1) {
+		t.text = append(t.text, s[last:m[0]])
+		t.paths = append(t.paths, s[m[2]:m[3]])
+		last = m[1]
+	}
+	t.text = append(t.text, s[last:])
+	for _, p := range t.text {
+		if strings.Contains(p, "{{") || strings.Contains(p, "}}") {
+			return nil, fmt.Errorf("invalid template %q", s)
+		}
+	}
+	return t, nil
+}
+
+// Static returns true if the template has no placeholders.
+func (t *Template) Static() bool {
+	return len(t.paths) == 0
+}
+
+// Execute returns the template string with placeholders replaced with the
+// values of the record fields. Record can be nil for static templates. Missing
+// fields are reported as errors.
+func (t *Template) Execute(rec map[string]interface{}) (string, error) {
+	var b strings.Builder
+	for i, p := range t.paths {
+		b.WriteString(t.text[i])
+		v, found := input.Lookup(rec, p)
+		if !found {
+			return "", fmt.Errorf("template field %q not found", p)
+		}
+		s, e := FormatValue(v)
+		if e != nil {
+			return "", e
+		}
+		b.WriteString(s)
+	}
+	b.WriteString(t.text[len(t.text)-1])
+	return b.String(), nil
+}
+
+// FormatValue formats a record field value as text. Strings are used as is,
+// other values are formatted as JSON.
+func FormatValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+	b, e := json.Marshal(v)
+	if e != nil {
+		return "", fmt.Errorf("unable to format value %v: %s", v, e)
+	}
+	return string(b), nil
+}
+
+// Record returns the record decoded from the message data, if any of the
+// templates requires it. Otherwise nil is returned.
+func Record(c input.Codec, d []byte, ts ...*Template) (map[string]interface{}, error) {
+	for _, t := range ts {
+		if t != nil && !t.Static() {
+			if c == nil {
+				return nil, fmt.Errorf("templates aren't supported for the input format")
+			}
+			return c.Decode(d)
+		}
+	}
+	return nil, nil
+}
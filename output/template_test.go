@@ -0,0 +1,63 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/pburakov/playback/input"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate(t *testing.T) {
+	tmpl, e := ParseTemplate("devices/{{device.id}}/{{ type }}")
+
+	assert.NoError(t, e)
+	assert.False(t, tmpl.Static())
+
+	rec := map[string]interface{}{
+		"device": map[string]interface{}{"id": map[string]interface{}{"long": 42}},
+		"type":   "telemetry",
+	}
+	s, e := tmpl.Execute(rec)
+
+	assert.NoError(t, e)
+	assert.Equal(t, "devices/42/telemetry", s)
+
+	_, e = tmpl.Execute(map[string]interface{}{"type": "telemetry"})
+
+	assert.Error(t, e)
+}
+
+func TestStaticTemplate(t *testing.T) {
+	tmpl, e := ParseTemplate("devices")
+
+	assert.NoError(t, e)
+	assert.True(t, tmpl.Static())
+
+	s, e := tmpl.Execute(nil)
+
+	assert.NoError(t, e)
+	assert.Equal(t, "devices", s)
+
+	_, e = ParseTemplate("devices/{{id")
+
+	assert.Error(t, e)
+}
+
+func TestRecord(t *testing.T) {
+	static, _ := ParseTemplate("devices")
+	dynamic, _ := ParseTemplate("{{id}}")
+
+	rec, e := Record(input.JSONCodec{}, []byte(`{"id":1}`), static)
+
+	assert.NoError(t, e)
+	assert.Nil(t, rec)
+
+	rec, e = Record(input.JSONCodec{}, []byte(`{"id":1}`), static, nil, dynamic)
+
+	assert.NoError(t, e)
+	assert.NotNil(t, rec)
+
+	_, e = Record(nil, []byte(`{"id":1}`), dynamic)
+
+	assert.Error(t, e)
+}
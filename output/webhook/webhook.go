@@ -0,0 +1,257 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+)
+
+const (
+	// maxBackoff limits the delay between retries, unless the server asks for
+	// a longer one with the Retry-After header.
+	maxBackoff = 30 * time.Second
+	// baseBackoff is the delay before the first retry.
+	baseBackoff = 100 * time.Millisecond
+)
+
+// Webhook is a sink sending messages to an HTTP endpoint. Every message is
+// sent as a request body, unless batching is enabled, in which case messages
+// are sent as JSON arrays. Requests failed with 5xx or 429 status codes are
+// retried, honouring the Retry-After header.
+type Webhook struct {
+	url         string
+	method      string
+	contentType string
+	codec       input.Codec
+	client      *http.Client
+	headers     map[string]*output.Template
+
+	retries int
+	limit   chan struct{}
+	batcher *output.Batcher[*output.Message, struct{}]
+}
+
+var _ output.Sink = (*Webhook)(nil)
+
+// Init returns a sink sending POST requests to the given URL with the given
+// content type and request timeout. The codec is used to decode the records
+// for header templates, and can be nil if no templates are used.
+func Init(url string, contentType string, codec input.Codec, to time.Duration) *Webhook {
+	return &Webhook{
+		url:         url,
+		method:      http.MethodPost,
+		contentType: contentType,
+		codec:       codec,
+		client:      &http.Client{Timeout: to},
+		headers:     make(map[string]*output.Template),
+		retries:     3,
+	}
+}
+
+// Method sets the HTTP request method.
+func (w *Webhook) Method(m string) {
+	w.method = m
+}
+
+// Header adds a request header. The value is a template, which placeholders
+// are replaced with the record field values, e.g. "{{user.id}}".
+func (w *Webhook) Header(name string, value string) error {
+	t, e := output.ParseTemplate(value)
+	if e != nil {
+		return e
+	}
+	w.headers[http.CanonicalHeaderKey(name)] = t
+	return nil
+}
+
+// Retries sets the number of times a request is retried.
+func (w *Webhook) Retries(n int) {
+	w.retries = n
+}
+
+// Limit sets the maximum number of concurrent requests, 0 - unlimited.
+func (w *Webhook) Limit(n int) {
+	if n > 0 {
+		w.limit = make(chan struct{}, n)
+	} else {
+		w.limit = nil
+	}
+}
+
+// Batch enables sending the messages in JSON arrays of up to the given size.
+// A batch is sent once it is full, or once the given delay passes since the
+// first message was added. The message data must be JSON. Header templates
+// are executed against the first message of a batch.
+func (w *Webhook) Batch(size int, delay time.Duration) {
+	if size > 1 {
+		w.batcher = output.InitBatcher(size, delay, w.sendBatch)
+	} else {
+		w.batcher = nil
+	}
+}
+
+// Publish sends the message and returns once the request succeeds or fails.
+// With batching enabled, it returns once the batch holding the message is sent.
+func (w *Webhook) Publish(ctx context.Context, m *output.Message) error {
+	if w.batcher == nil {
+		if e := w.send(ctx, m.Data, m); e != nil {
+			return e
+		}
+		log.Printf("Sent message (%s)", m.Tag)
+		return nil
+	}
+
+	_, n, e := w.batcher.Add(ctx, m)
+	if e != nil {
+		return e
+	}
+	log.Printf("Sent message in a batch of %d (%s)", n, m.Tag)
+	return nil
+}
+
+// sendBatch sends the messages as a JSON array. The first message is used to
+// execute the header templates.
+func (w *Webhook) sendBatch(ctx context.Context, ms []*output.Message) ([]struct{}, error) {
+	body := []byte{'['}
+	for i, m := range ms {
+		if i > 0 {
+			body = append(body, ',')
+		}
+		body = append(body, bytes.TrimSpace(m.Data)...)
+	}
+	body = append(body, ']')
+	return nil, w.send(ctx, body, ms[0])
+}
+
+// send makes the request, retrying it on 5xx and 429 responses and network
+// errors. Message is used to execute the header templates.
+func (w *Webhook) send(ctx context.Context, body []byte, m *output.Message) error {
+	h, e := w.header(m)
+	if e != nil {
+		return e
+	}
+
+	if w.limit != nil {
+		select {
+		case w.limit <- struct{}{}:
+			defer func() { <-w.limit }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for i := 0; ; i++ {
+		wait, e := w.request(ctx, body, h)
+		if e == nil {
+			return nil
+		}
+		if wait < 0 || i >= w.retries {
+			return e
+		}
+		if wait == 0 {
+			wait = backoff(i)
+		}
+		log.Printf("Retrying request in %s: %s (%s)", wait, e, m.Tag)
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// request makes a single request. On failure, it returns the delay requested
+// by the server before retrying, zero if the request can be retried with a
+// default backoff, or a negative value if it must not be retried.
+func (w *Webhook) request(ctx context.Context, body []byte, h http.Header) (time.Duration, error) {
+	req, e := http.NewRequest(w.method, w.url, bytes.NewReader(body))
+	if e != nil {
+		return -1, e
+	}
+	req = req.WithContext(ctx)
+	req.Header = h
+
+	res, e := w.client.Do(req)
+	if e != nil {
+		if ctx.Err() != nil {
+			return -1, ctx.Err()
+		}
+		return 0, e
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	switch {
+	case res.StatusCode < 300:
+		return 0, nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return retryAfter(res.Header.Get("Retry-After")), fmt.Errorf("unexpected response status %q", res.Status)
+	default:
+		return -1, fmt.Errorf("unexpected response status %q", res.Status)
+	}
+}
+
+// header returns the request headers for the message.
+func (w *Webhook) header(m *output.Message) (http.Header, error) {
+	h := make(http.Header)
+	if len(w.contentType) > 0 {
+		h.Set("Content-Type", w.contentType)
+	}
+	for k, v := range m.Attributes {
+		h.Set(k, v)
+	}
+
+	ts := make([]*output.Template, 0, len(w.headers))
+	for _, t := range w.headers {
+		ts = append(ts, t)
+	}
+	rec, e := output.Record(w.codec, m.Data, ts...)
+	if e != nil {
+		return nil, fmt.Errorf("unable to decode record for header templates: %s", e)
+	}
+	for k, t := range w.headers {
+		v, e := t.Execute(rec)
+		if e != nil {
+			return nil, e
+		}
+		h.Set(k, v)
+	}
+	return h, nil
+}
+
+// retryAfter parses the Retry-After header value, either in seconds or as an
+// HTTP date. Zero is returned if the value is missing or invalid.
+func retryAfter(v string) time.Duration {
+	if len(v) == 0 {
+		return 0
+	}
+	if s, e := strconv.Atoi(v); e == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, e := http.ParseTime(v); e == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// backoff returns the exponential delay before the given retry.
+func backoff(i int) time.Duration {
+	d := baseBackoff << uint(i)
+	if d > maxBackoff || d <= 0 {
+		return maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,168 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTimeout = 5 * time.Second
+
+func TestPublish(t *testing.T) {
+	var got *http.Request
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got, body = r, string(b)
+	}))
+	defer srv.Close()
+
+	s := Init(srv.URL, "application/json", input.JSONCodec{}, testTimeout)
+	assert.NoError(t, s.Header("X-User", "user-{{user.id}}"))
+	s.Method(http.MethodPut)
+
+	e := s.Publish(context.Background(), &output.Message{
+		Tag:        "no=1",
+		Data:       []byte(`{"user":{"id":42}}`),
+		Attributes: map[string]string{"X-Orig": "foo"},
+	})
+
+	assert.NoError(t, e)
+	assert.Equal(t, http.MethodPut, got.Method)
+	assert.Equal(t, `{"user":{"id":42}}`, body)
+	assert.Equal(t, "application/json", got.Header.Get("Content-Type"))
+	assert.Equal(t, "user-42", got.Header.Get("X-User"))
+	assert.Equal(t, "foo", got.Header.Get("X-Orig"))
+
+	e = s.Publish(context.Background(), &output.Message{Data: []byte(`{}`)})
+
+	assert.Error(t, e)
+}
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	s := Init(srv.URL, "", nil, testTimeout)
+
+	e := s.Publish(context.Background(), &output.Message{Data: []byte("foo")})
+
+	assert.NoError(t, e)
+	assert.Equal(t, int32(3), calls)
+
+	s.Retries(1)
+	atomic.StoreInt32(&calls, 0)
+
+	e = s.Publish(context.Background(), &output.Message{Data: []byte("foo")})
+
+	assert.Error(t, e)
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestNoRetry(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	e := Init(srv.URL, "", nil, testTimeout).Publish(context.Background(), &output.Message{Data: []byte("foo")})
+
+	assert.Error(t, e)
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestBatch(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(b))
+	}))
+	defer srv.Close()
+
+	s := Init(srv.URL, "application/json", nil, testTimeout)
+	s.Batch(2, time.Hour)
+
+	var wg sync.WaitGroup
+	for _, d := range []string{`{"id":1}`, `{"id":1}`} {
+		wg.Add(1)
+		go func(d string) {
+			assert.NoError(t, s.Publish(context.Background(), &output.Message{Data: []byte(d)}))
+			wg.Done()
+		}(d)
+	}
+	wg.Wait()
+
+	// an incomplete batch is sent after the delay
+	s.Batch(2, 10*time.Millisecond)
+	assert.NoError(t, s.Publish(context.Background(), &output.Message{Data: []byte("{\"id\":2}\n")}))
+
+	assert.Equal(t, []string{`[{"id":1},{"id":1}]`, `[{"id":2}]`}, bodies)
+}
+
+func TestLimit(t *testing.T) {
+	var cur, max int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&cur, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&cur, -1)
+	}))
+	defer srv.Close()
+
+	s := Init(srv.URL, "", nil, testTimeout)
+	s.Limit(2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			assert.NoError(t, s.Publish(context.Background(), &output.Message{Data: []byte("foo")}))
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, max <= 2)
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, 3*time.Second, retryAfter("3"))
+	assert.Equal(t, time.Duration(0), retryAfter(""))
+	assert.Equal(t, time.Duration(0), retryAfter("soon"))
+	assert.True(t, retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)) > 30*time.Second)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, baseBackoff, backoff(0))
+	assert.Equal(t, 4*baseBackoff, backoff(2))
+	assert.Equal(t, maxBackoff, backoff(20))
+	assert.Equal(t, maxBackoff, backoff(100))
+}
@@ -0,0 +1,318 @@
+// Package playback provides a reusable player replaying records from an input
+// reader into an output sink, for embedding replays in-process.
+package playback
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pburakov/playback/clock"
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+	"github.com/pburakov/playback/runner"
+)
+
+// Player replays records from an input reader into an output sink using one
+// of the playback modes. Player is constructed with New and configured with
+// functional options.
+type Player struct {
+	reader input.FileReader
+	sink   output.Sink
+	play   func(ctx context.Context, r *runner.Runner, in input.FileReader, action func(time.Time, string, []byte)) error
+	clock  clock.Clock
+	rand   *rand.Rand
+
+	timeAttr string
+	startAt  time.Time
+	anchorTS time.Time
+	anchorAt time.Time
+
+	amplify uint
+	mutate  func([]byte, uint) ([]byte, error)
+
+	onPublish func(m *output.Message)
+	onError   func(m *output.Message, e error)
+}
+
+// Stats holds playback counters.
+type Stats struct {
+	// Sent is the number of messages successfully published.
+	Sent uint64
+	// Failed is the number of messages the sink failed to publish.
+	Failed uint64
+}
+
+// Option configures the player.
+type Option func(p *Player) error
+
+// New constructs a player. Reader and sink options are required. Unless
+// configured otherwise, the player runs in instant mode.
+func New(opts ...Option) (*Player, error) {
+	p := &Player{amplify: 1, clock: clock.Real, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	WithInstant()(p)
+	for _, o := range opts {
+		if e := o(p); e != nil {
+			return nil, e
+		}
+	}
+	if p.reader == nil {
+		return nil, errors.New("input reader is not set")
+	}
+	if p.sink == nil {
+		return nil, errors.New("output sink is not set")
+	}
+	return p, nil
+}
+
+// WithReader sets the input reader.
+func WithReader(r input.FileReader) Option {
+	return func(p *Player) error {
+		p.reader = r
+		return nil
+	}
+}
+
+// WithSink sets the output sink.
+func WithSink(s output.Sink) Option {
+	return func(p *Player) error {
+		p.sink = s
+		return nil
+	}
+}
+
+// WithClock sets the clock used for scheduling, e.g. a fake clock in tests.
+// The clock is also set on the reader, if it produces time-dependent data,
+// such as generated records. Wall clock is used by default.
+func WithClock(c clock.Clock) Option {
+	return func(p *Player) error {
+		p.clock = c
+		return nil
+	}
+}
+
+// WithSeed sets the seed of the random source used for scheduling (such as
+// jitter), making the playback timing reproducible. Time-based seed is used by
+// default.
+func WithSeed(seed int64) Option {
+	return func(p *Player) error {
+		p.rand = rand.New(rand.NewSource(seed))
+		return nil
+	}
+}
+
+// WithRand sets the random source used for scheduling and for drawing
+// statistical gaps. The source is only used by the goroutine reading the
+// input, so it can be shared with the input reader, e.g. a sampling reader
+// or a generator, making the whole playback reproducible from a single seed.
+func WithRand(r *rand.Rand) Option {
+	return func(p *Player) error {
+		p.rand = r
+		return nil
+	}
+}
+
+// WithAnchor maps the given event timestamp to the given wall-clock time in
+// relative and simulated modes, instead of mapping the first record timestamp
+// to the playback start. Players anchored to the same point keep the relative
+// timing of events across their inputs.
+func WithAnchor(ts time.Time, at time.Time) Option {
+	return func(p *Player) error {
+		if ts.IsZero() || at.IsZero() {
+			return errors.New("anchor timestamps must be set")
+		}
+		p.anchorTS, p.anchorAt = ts, at
+		return nil
+	}
+}
+
+// WithStartAt delays the playback until the given time. In simulated mode, the
+// virtual clock is moved forward to the given time instead.
+func WithStartAt(t time.Time) Option {
+	return func(p *Player) error {
+		p.startAt = t
+		return nil
+	}
+}
+
+// WithInstant sets instant playback mode.
+func WithInstant() Option {
+	return func(p *Player) error {
+		p.play = func(ctx context.Context, r *runner.Runner, in input.FileReader, action func(time.Time, string, []byte)) error {
+			return r.PlayInstant(ctx, in, untimed(action))
+		}
+		return nil
+	}
+}
+
+// WithPaced sets paced playback mode with the given delay between messages
+// and max jitter (in milliseconds).
+func WithPaced(del time.Duration, mjMSec int) Option {
+	return func(p *Player) error {
+		p.play = func(ctx context.Context, r *runner.Runner, in input.FileReader, action func(time.Time, string, []byte)) error {
+			return r.PlayPaced(ctx, in, untimed(action), del, mjMSec)
+		}
+		return nil
+	}
+}
+
+// WithRate sets paced playback mode with the given constant rate, in messages
+// per second.
+func WithRate(rate float64) Option {
+	return func(p *Player) error {
+		if rate <= 0 {
+			return errors.New("rate must be positive")
+		}
+		return WithPaced(time.Duration(float64(time.Second)/rate), 0)(p)
+	}
+}
+
+// WithRelative sets relative playback mode with the given lookahead window and
+// max jitter (in milliseconds).
+func WithRelative(lh time.Duration, mjMSec int) Option {
+	return func(p *Player) error {
+		p.play = func(ctx context.Context, r *runner.Runner, in input.FileReader, action func(time.Time, string, []byte)) error {
+			return r.PlayRelative(ctx, in, untimed(action), lh, mjMSec)
+		}
+		return nil
+	}
+}
+
+// WithSimulated sets simulation mode, which runs the relative playback
+// scheduling against a virtual clock and publishes the messages without
+// waiting. The intended send time is set as the message timestamp and, unless
+// the attribute name is empty, as a message attribute in RFC 3339 format.
+func WithSimulated(lh time.Duration, mjMSec int, attr string) Option {
+	return func(p *Player) error {
+		p.play = func(ctx context.Context, r *runner.Runner, in input.FileReader, action func(time.Time, string, []byte)) error {
+			return r.PlaySimulated(ctx, in, action, lh, mjMSec)
+		}
+		p.timeAttr = attr
+		return nil
+	}
+}
+
+// WithProfile sets profiled playback mode with the given rate function, which
+// returns the rate in messages per second for the time elapsed since the start.
+func WithProfile(rate func(time.Duration) float64) Option {
+	return func(p *Player) error {
+		p.play = func(ctx context.Context, r *runner.Runner, in input.FileReader, action func(time.Time, string, []byte)) error {
+			return r.PlayProfile(ctx, in, untimed(action), rate)
+		}
+		return nil
+	}
+}
+
+// WithStatistical sets statistical playback mode with the given function
+// drawing gaps between messages from the random source of the player.
+func WithStatistical(gap func(*rand.Rand) time.Duration) Option {
+	return func(p *Player) error {
+		p.play = func(ctx context.Context, r *runner.Runner, in input.FileReader, action func(time.Time, string, []byte)) error {
+			return r.PlayStatistical(ctx, in, untimed(action), gap)
+		}
+		return nil
+	}
+}
+
+// WithAmplify publishes every record n times. Copies after the first one are
+// passed through an optional mutation function (can be nil).
+func WithAmplify(n uint, mutate func([]byte, uint) ([]byte, error)) Option {
+	return func(p *Player) error {
+		if n == 0 {
+			return errors.New("amplification factor must be at least 1")
+		}
+		p.amplify = n
+		p.mutate = mutate
+		return nil
+	}
+}
+
+// OnPublish sets a hook called after every successfully published message.
+func OnPublish(f func(m *output.Message)) Option {
+	return func(p *Player) error {
+		p.onPublish = f
+		return nil
+	}
+}
+
+// OnError sets a hook called for every message the sink failed to publish.
+// By default, errors are logged.
+func OnError(f func(m *output.Message, e error)) Option {
+	return func(p *Player) error {
+		p.onError = f
+		return nil
+	}
+}
+
+// Play runs the playback until the input is exhausted, a reader error occurs
+// or the context is cancelled, and waits for all spawned messages to complete.
+// Sink errors don't stop the playback and are reported via the error hook.
+// Messages are published asynchronously if the sink is an output.AsyncSink.
+func (p *Player) Play(ctx context.Context) (Stats, error) {
+	var sent, failed uint64
+	var pending sync.WaitGroup
+
+	done := func(m *output.Message, e error) {
+		if e != nil {
+			atomic.AddUint64(&failed, 1)
+			if p.onError != nil {
+				p.onError(m, e)
+			} else {
+				log.Printf("Error publishing message: %s (%s)", e, m.Tag)
+			}
+			return
+		}
+		atomic.AddUint64(&sent, 1)
+		if p.onPublish != nil {
+			p.onPublish(m)
+		}
+	}
+	publish := func(at time.Time, tag string, d []byte) {
+		if at.IsZero() {
+			at = p.clock.Now()
+		}
+		m := &output.Message{Tag: tag, Data: d, Timestamp: at}
+		if p.timeAttr != "" {
+			m.Attributes = map[string]string{p.timeAttr: at.Format(time.RFC3339Nano)}
+		}
+		if s, ok := p.sink.(output.AsyncSink); ok {
+			pending.Add(1)
+			s.PublishAsync(ctx, m, func(e error) {
+				done(m, e)
+				pending.Done()
+			})
+			return
+		}
+		done(m, p.sink.Publish(ctx, m))
+	}
+	action := publish
+	if p.amplify > 1 {
+		action = func(at time.Time, tag string, d []byte) {
+			runner.Amplify(func(tag string, d []byte) {
+				publish(at, tag, d)
+			}, p.amplify, p.mutate)(tag, d)
+		}
+	}
+
+	input.SetClock(p.reader, p.clock)
+	r := runner.New(p.clock, p.rand)
+	if !p.anchorTS.IsZero() {
+		r.Anchor(p.anchorTS, p.anchorAt)
+	}
+	r.StartAt(p.startAt)
+	e := p.play(ctx, r, p.reader, action)
+	pending.Wait()
+	return Stats{Sent: atomic.LoadUint64(&sent), Failed: atomic.LoadUint64(&failed)}, e
+}
+
+// untimed adapts the action for playback modes which don't provide the
+// intended send time.
+func untimed(action func(time.Time, string, []byte)) func(string, []byte) {
+	return func(tag string, d []byte) {
+		action(time.Time{}, tag, d)
+	}
+}
@@ -0,0 +1,239 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pburakov/playback/clock"
+	"github.com/pburakov/playback/input/json"
+	"github.com/pburakov/playback/output"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testFile     = "input/json/input_test.json"
+	testColumn   = "bar"
+	testTSFormat = "2006-01-02T15:04:05.999999"
+)
+
+func TestPlay(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	var published uint64
+
+	p, e := New(
+		WithReader(testReader(t)),
+		WithSink(output.SinkFunc(func(ctx context.Context, m *output.Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, string(m.Data))
+			return nil
+		})),
+		WithRate(100),
+		OnPublish(func(m *output.Message) {
+			mu.Lock()
+			defer mu.Unlock()
+			published++
+		}),
+	)
+
+	assert.NoError(t, e)
+
+	stats, e := p.Play(context.Background())
+
+	assert.NoError(t, e)
+	assert.Equal(t, Stats{Sent: 2}, stats)
+	assert.Equal(t, uint64(2), published)
+	assert.Len(t, got, 2)
+}
+
+func TestPlayFakeClock(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	var mu sync.Mutex
+	var got []time.Time
+
+	p, e := New(
+		WithReader(testReader(t)),
+		WithSink(output.SinkFunc(func(ctx context.Context, m *output.Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, m.Timestamp)
+			return nil
+		})),
+		WithClock(c),
+		WithSeed(1),
+		WithPaced(time.Hour, 0),
+	)
+
+	assert.NoError(t, e)
+
+	done := make(chan Stats, 1)
+	go func() {
+		stats, _ := p.Play(context.Background())
+		done <- stats
+	}()
+
+	// an hour between records passes without real waiting
+	for i := 0; i < 2; i++ {
+		c.BlockUntil(1)
+		c.Advance(time.Hour)
+	}
+	assert.Equal(t, Stats{Sent: 2}, <-done)
+	assert.Equal(t, time.Unix(0, 0).Add(2*time.Hour), c.Now())
+	// messages are timestamped by the player clock
+	assert.Equal(t, []time.Time{time.Unix(0, 0), time.Unix(0, 0).Add(time.Hour)}, got)
+}
+
+func TestPlaySimulated(t *testing.T) {
+	start := time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	var got []*output.Message
+
+	p, e := New(
+		WithReader(testReader(t)),
+		WithSink(output.SinkFunc(func(ctx context.Context, m *output.Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, m)
+			return nil
+		})),
+		WithClock(clock.NewFake(start)),
+		WithSimulated(time.Second, 0, "intended_time"),
+		WithAmplify(2, nil),
+	)
+
+	assert.NoError(t, e)
+
+	stats, e := p.Play(context.Background())
+
+	assert.NoError(t, e)
+	assert.Equal(t, Stats{Sent: 4}, stats)
+	// the second record is older than the first one, so it was due in the past
+	var intended []string
+	for _, m := range got {
+		assert.Equal(t, m.Timestamp.Format(time.RFC3339Nano), m.Attributes["intended_time"])
+		intended = append(intended, m.Attributes["intended_time"])
+	}
+	assert.ElementsMatch(t, []string{
+		"2026-10-18T15:00:00Z", "2026-10-18T15:00:00Z",
+		"2026-10-13T02:02:37.812768Z", "2026-10-13T02:02:37.812768Z",
+	}, intended)
+}
+
+func TestPlaySinkErrors(t *testing.T) {
+	expected := errors.New("publish error")
+	var failed []error
+	var mu sync.Mutex
+
+	p, _ := New(
+		WithReader(testReader(t)),
+		WithSink(output.SinkFunc(func(ctx context.Context, m *output.Message) error {
+			return expected
+		})),
+		WithAmplify(3, nil),
+		OnError(func(m *output.Message, e error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, e)
+		}),
+	)
+
+	stats, e := p.Play(context.Background())
+
+	assert.NoError(t, e)
+	assert.Equal(t, Stats{Failed: 6}, stats)
+	assert.Len(t, failed, 6)
+}
+
+// asyncSink confirms the messages in the background after a delay, failing
+// the ones with the given tag.
+type asyncSink struct {
+	fail string
+}
+
+func (s asyncSink) Publish(ctx context.Context, m *output.Message) error {
+	panic("unexpected synchronous publish")
+}
+
+func (s asyncSink) PublishAsync(ctx context.Context, m *output.Message, done func(error)) {
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if m.Tag == s.fail {
+			done(errors.New("publish error"))
+			return
+		}
+		done(nil)
+	}()
+}
+
+func TestPlayAsyncSink(t *testing.T) {
+	var mu sync.Mutex
+	var failed []string
+
+	p, _ := New(
+		WithReader(testReader(t)),
+		WithSink(asyncSink{fail: "no=2"}),
+		OnError(func(m *output.Message, e error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, m.Tag)
+		}),
+	)
+
+	stats, e := p.Play(context.Background())
+
+	assert.NoError(t, e)
+	// pending messages are awaited
+	assert.Equal(t, Stats{Sent: 1, Failed: 1}, stats)
+	assert.Equal(t, []string{"no=2"}, failed)
+}
+
+func TestPlayCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	p, _ := New(
+		WithReader(testReader(t)),
+		WithSink(output.SinkFunc(func(ctx context.Context, m *output.Message) error {
+			return nil
+		})),
+		WithPaced(time.Hour, 0),
+	)
+
+	start := time.Now()
+	stats, e := p.Play(ctx)
+
+	assert.Equal(t, context.DeadlineExceeded, e)
+	assert.Equal(t, Stats{Sent: 1}, stats)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestNewErrors(t *testing.T) {
+	sink := WithSink(output.SinkFunc(func(ctx context.Context, m *output.Message) error {
+		return nil
+	}))
+
+	_, e := New(sink)
+	assert.Error(t, e)
+
+	_, e = New(WithReader(testReader(t)))
+	assert.Error(t, e)
+
+	_, e = New(WithReader(testReader(t)), sink, WithRate(0))
+	assert.Error(t, e)
+
+	_, e = New(WithReader(testReader(t)), sink, WithAmplify(0, nil))
+	assert.Error(t, e)
+
+	_, e = New(WithReader(testReader(t)), sink, WithAnchor(time.Time{}, time.Now()))
+	assert.Error(t, e)
+}
+
+func testReader(t *testing.T) *json.JSONReader {
+	r, e := json.Init(testFile, testColumn, testTSFormat)
+	assert.NoError(t, e)
+	return r
+}
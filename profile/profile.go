@@ -0,0 +1,165 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Profile describes message rate (in messages per second) as a function of
+// the time elapsed since the start of the playback. Profile is a sequence of
+// segments played one after another. Once the last segment is over, the rate
+// at the end of the last segment is maintained.
+type Profile struct {
+	segments []segment
+}
+
+// segment is a part of the profile with a fixed duration. Zero duration means
+// the segment is open-ended. Peak is the highest rate of the segment.
+type segment struct {
+	d    time.Duration
+	peak float64
+	rate func(t time.Duration) float64
+}
+
+// Parse parses comma-separated list of profile segments. Supported segments
+// are (rates are in messages per second, durations are Go duration strings):
+//
+//	const:RATE[:DURATION]                  constant rate
+//	ramp:FROM:TO:DURATION                  linear change of rate
+//	sine:MIN:MAX:PERIOD[:DURATION]         sinusoidal rate, starting at MIN
+//	burst:BASE:PEAK:PERIOD:LEN[:DURATION]  PEAK rate for LEN every PERIOD, BASE otherwise
+//
+// Only the last segment can be open-ended. Step profile is described as a
+// sequence of constant segments, e.g. "const:10:1m,const:100:1m,const:500".
+// Profiles ending at zero rate are rejected, since the playback would never
+// end.
+func Parse(s string) (*Profile, error) {
+	if len(strings.TrimSpace(s)) == 0 {
+		return nil, errors.New("empty profile")
+	}
+	p := &Profile{}
+	parts := strings.Split(s, ",")
+	for i, part := range parts {
+		seg, e := parseSegment(strings.TrimSpace(part))
+		if e != nil {
+			return nil, fmt.Errorf("invalid profile segment %q: %s", part, e)
+		}
+		if seg.d == 0 && i < len(parts)-1 {
+			return nil, fmt.Errorf("invalid profile segment %q: only the last segment can be open-ended", part)
+		}
+		p.segments = append(p.segments, seg)
+	}
+	if p.ends() {
+		return nil, errors.New("profile ends at zero rate")
+	}
+	return p, nil
+}
+
+// ends reports whether the rate drops to zero for good once the profile is
+// over.
+func (p *Profile) ends() bool {
+	last := p.segments[len(p.segments)-1]
+	if last.d == 0 {
+		return last.peak == 0
+	}
+	var total time.Duration
+	for _, s := range p.segments {
+		total += s.d
+	}
+	return p.Rate(total) == 0
+}
+
+// Rate returns the message rate at the given time elapsed since the start.
+func (p *Profile) Rate(t time.Duration) float64 {
+	for i, s := range p.segments {
+		if s.d == 0 || t < s.d || i == len(p.segments)-1 {
+			if s.d != 0 && t > s.d {
+				t = s.d
+			}
+			return math.Max(0, s.rate(t))
+		}
+		t -= s.d
+	}
+	return 0
+}
+
+func parseSegment(s string) (segment, error) {
+	args := strings.Split(s, ":")
+	switch args[0] {
+	case "const":
+		return parseArgs(args[1:], 1, func(r []float64, d []time.Duration) func(time.Duration) float64 {
+			return func(time.Duration) float64 { return r[0] }
+		})
+	case "ramp":
+		seg, e := parseArgs(args[1:], 2, func(r []float64, d []time.Duration) func(time.Duration) float64 {
+			return func(t time.Duration) float64 {
+				return r[0] + (r[1]-r[0])*float64(t)/float64(d[0])
+			}
+		})
+		if e == nil && seg.d == 0 {
+			return seg, errors.New("ramp duration is required")
+		}
+		return seg, e
+	case "sine":
+		return parseArgs(args[1:], 2, func(r []float64, d []time.Duration) func(time.Duration) float64 {
+			return func(t time.Duration) float64 {
+				phase := 2 * math.Pi * float64(t) / float64(d[0])
+				return r[0] + (r[1]-r[0])*(1-math.Cos(phase))/2
+			}
+		}, "period")
+	case "burst":
+		return parseArgs(args[1:], 2, func(r []float64, d []time.Duration) func(time.Duration) float64 {
+			return func(t time.Duration) float64 {
+				if t%d[0] < d[1] {
+					return r[1]
+				}
+				return r[0]
+			}
+		}, "period", "length")
+	default:
+		return segment{}, fmt.Errorf("unknown segment type %q", args[0])
+	}
+}
+
+// parseArgs parses the given number of rates, followed by named required
+// durations and an optional segment duration, and constructs the segment
+// using the rate function builder.
+func parseArgs(args []string, rates int, f func([]float64, []time.Duration) func(time.Duration) float64, durations ...string) (segment, error) {
+	if len(args) < rates+len(durations) || len(args) > rates+len(durations)+1 {
+		return segment{}, fmt.Errorf("unexpected number of arguments %d", len(args))
+	}
+	var r []float64
+	for _, a := range args[:rates] {
+		v, e := strconv.ParseFloat(a, 64)
+		if e != nil || v < 0 {
+			return segment{}, fmt.Errorf("invalid rate %q", a)
+		}
+		r = append(r, v)
+	}
+	var d []time.Duration
+	for _, a := range args[rates:] {
+		v, e := time.ParseDuration(a)
+		if e != nil || v <= 0 {
+			return segment{}, fmt.Errorf("invalid duration %q", a)
+		}
+		d = append(d, v)
+	}
+	seg := segment{}
+	for _, v := range r {
+		seg.peak = math.Max(seg.peak, v)
+	}
+	if len(d) > len(durations) {
+		seg.d = d[len(d)-1]
+	}
+	if len(durations) == 0 {
+		// Segment duration is the only duration, e.g. ramp
+		seg.rate = f(r, d)
+	} else {
+		seg.rate = f(r, d[:len(durations)])
+	}
+	return seg, nil
+}
@@ -0,0 +1,85 @@
+package profile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRamp(t *testing.T) {
+	p, e := Parse("ramp:10:1000:5m")
+
+	assert.NoError(t, e)
+	assert.Equal(t, 10.0, p.Rate(0))
+	assert.Equal(t, 505.0, p.Rate(150*time.Second))
+	assert.Equal(t, 1000.0, p.Rate(5*time.Minute))
+	assert.Equal(t, 1000.0, p.Rate(time.Hour))
+}
+
+func TestSteps(t *testing.T) {
+	p, e := Parse("const:10:1m, const:100:1m, const:500")
+
+	assert.NoError(t, e)
+	assert.Equal(t, 10.0, p.Rate(30*time.Second))
+	assert.Equal(t, 100.0, p.Rate(90*time.Second))
+	assert.Equal(t, 500.0, p.Rate(time.Hour))
+}
+
+func TestSine(t *testing.T) {
+	p, e := Parse("sine:100:1000:24h")
+
+	assert.NoError(t, e)
+	assert.InDelta(t, 100.0, p.Rate(0), 0.001)
+	assert.InDelta(t, 550.0, p.Rate(6*time.Hour), 0.001)
+	assert.InDelta(t, 1000.0, p.Rate(12*time.Hour), 0.001)
+	assert.InDelta(t, 100.0, p.Rate(24*time.Hour), 0.001)
+}
+
+func TestBurst(t *testing.T) {
+	p, e := Parse("const:5:10s,burst:10:200:1m:5s:10m30s")
+
+	assert.NoError(t, e)
+	assert.Equal(t, 5.0, p.Rate(5*time.Second))
+	assert.Equal(t, 200.0, p.Rate(12*time.Second))
+	assert.Equal(t, 10.0, p.Rate(20*time.Second))
+	assert.Equal(t, 200.0, p.Rate(71*time.Second))
+	// Last rate of the finite profile is maintained
+	assert.Equal(t, 10.0, p.Rate(time.Hour))
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"foo:10",
+		"const",
+		"const:-1",
+		"const:foo",
+		"const:10,const:20",
+		"ramp:10:20",
+		"ramp:10:20:foo",
+		"sine:1:2",
+		"burst:1:2:1m",
+		"const:1:1m:1m",
+		"const:0",
+		"ramp:1000:0:5m",
+		"const:10:1m,const:0:1m",
+		"sine:0:0:1m",
+		"burst:0:0:1m:1s",
+	} {
+		_, e := Parse(s)
+		assert.Error(t, e, s)
+	}
+}
+
+func TestParseZeroRate(t *testing.T) {
+	for _, s := range []string{
+		"const:0:1m,const:10",
+		"ramp:0:10:1m",
+		"sine:0:10:1m",
+		"burst:0:10:1m:1s",
+	} {
+		_, e := Parse(s)
+		assert.NoError(t, e, s)
+	}
+}
@@ -0,0 +1,139 @@
+package rewrite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/output"
+)
+
+// Rewriter replaces the event timestamp in the binary record data with the
+// replay time, preserving the original serialization of the timestamp value.
+// Optionally, the original timestamp is stored under a separate record field
+// or in a message attribute.
+type Rewriter struct {
+	codec  input.Codec
+	col    string
+	format string
+	shift  time.Duration
+
+	// optional field and attribute for the original timestamp
+	origCol  string
+	origAttr string
+}
+
+// Init returns a rewriter for records decoded with the given codec. Column and
+// format describe the timestamp column. Shift is added to the replay time
+// before it is written into the record.
+func Init(c input.Codec, col string, format string, shift time.Duration) *Rewriter {
+	return &Rewriter{codec: c, col: col, format: format, shift: shift}
+}
+
+// KeepOriginal sets the record field and the message attribute for storing the
+// original timestamp value. Empty names are ignored. The original value is
+// copied into the field as is, and formatted using the timestamp format in the
+// attribute. With codecs bound to a schema, such as Avro, the field must be
+// defined in the schema.
+func (r *Rewriter) KeepOriginal(col string, attr string) error {
+	if s, ok := r.codec.(input.SchemaCodec); ok && len(col) > 0 {
+		if _, found := s.Field(col); !found {
+			return fmt.Errorf("original timestamp field %q is not defined in the record schema", col)
+		}
+	}
+	r.origCol = col
+	r.origAttr = attr
+	return nil
+}
+
+// Rewrite sets the timestamp column of the record data to the given replay
+// time (adjusted for the shift) and returns updated data and message
+// attributes.
+func (r *Rewriter) Rewrite(d []byte, now time.Time) ([]byte, map[string]string, error) {
+	rec, e := r.codec.Decode(d)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	var attrs map[string]string
+	if len(r.origAttr) > 0 {
+		ts, e := input.Timestamp(rec, r.col, r.format)
+		if e != nil {
+			return nil, nil, e
+		}
+		attrs = map[string]string{r.origAttr: ts.Format(r.format)}
+	}
+	if len(r.origCol) > 0 {
+		rec[r.origCol] = rec[r.col]
+	}
+
+	if e := input.SetTimestamp(rec, r.col, r.format, now.Add(r.shift)); e != nil {
+		return nil, nil, e
+	}
+	d, e = r.codec.Encode(rec)
+	return d, attrs, e
+}
+
+// Wrap returns a sink which rewrites the timestamps of the messages with the
+// actual send time before passing them to the given sink. The intended send
+// time of the message is used instead, if set. Attributes produced by the
+// rewriter are merged into the message attributes. The returned sink is an
+// output.AsyncSink if the given one is.
+func (r *Rewriter) Wrap(s output.Sink) output.Sink {
+	w := &sink{r: r, s: s}
+	if a, ok := s.(output.AsyncSink); ok {
+		return &asyncSink{sink: w, a: a}
+	}
+	return w
+}
+
+// sink rewrites the messages passed to the wrapped sink.
+type sink struct {
+	r *Rewriter
+	s output.Sink
+}
+
+func (w *sink) Publish(ctx context.Context, m *output.Message) error {
+	m, e := w.r.message(m)
+	if e != nil {
+		return e
+	}
+	return w.s.Publish(ctx, m)
+}
+
+// asyncSink rewrites the messages passed to the wrapped asynchronous sink.
+type asyncSink struct {
+	*sink
+	a output.AsyncSink
+}
+
+func (w *asyncSink) PublishAsync(ctx context.Context, m *output.Message, done func(error)) {
+	m, e := w.r.message(m)
+	if e != nil {
+		done(e)
+		return
+	}
+	w.a.PublishAsync(ctx, m, done)
+}
+
+// message returns the message with the rewritten timestamp.
+func (r *Rewriter) message(m *output.Message) (*output.Message, error) {
+	now := m.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	d, attrs, e := r.Rewrite(m.Data, now)
+	if e != nil {
+		return nil, fmt.Errorf("error rewriting timestamp: %s", e)
+	}
+	for k, v := range m.Attributes {
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		if _, found := attrs[k]; !found {
+			attrs[k] = v
+		}
+	}
+	return &output.Message{Tag: m.Tag, Data: d, Attributes: attrs, Timestamp: m.Timestamp}, nil
+}
@@ -0,0 +1,134 @@
+package rewrite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/input/avro"
+	"github.com/pburakov/playback/output"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testColumn   = "ts"
+	testTSFormat = "2006-01-02T15:04:05.999999Z07:00"
+	testPayload  = `{"ts":"2019-02-11T15:20:09.514626Z","val":"foo"}`
+)
+
+var testNow = time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+
+func TestRewrite(t *testing.T) {
+	r := Init(input.JSONCodec{}, testColumn, testTSFormat, 0)
+
+	d, attrs, e := r.Rewrite([]byte(testPayload), testNow)
+
+	assert.NoError(t, e)
+	assert.Nil(t, attrs)
+	assert.Equal(t, `{"ts":"2026-10-18T15:00:00Z","val":"foo"}`, string(d))
+}
+
+func TestRewriteWithShift(t *testing.T) {
+	r := Init(input.JSONCodec{}, testColumn, testTSFormat, -time.Hour)
+
+	d, _, e := r.Rewrite([]byte(testPayload), testNow)
+
+	assert.NoError(t, e)
+	assert.Equal(t, `{"ts":"2026-10-18T14:00:00Z","val":"foo"}`, string(d))
+}
+
+func TestKeepOriginal(t *testing.T) {
+	r := Init(input.JSONCodec{}, testColumn, testTSFormat, 0)
+	assert.NoError(t, r.KeepOriginal("orig_ts", "orig"))
+
+	d, attrs, e := r.Rewrite([]byte(testPayload), testNow)
+
+	assert.NoError(t, e)
+	assert.Equal(t, map[string]string{"orig": "2019-02-11T15:20:09.514626Z"}, attrs)
+	assert.Equal(t, `{"orig_ts":"2019-02-11T15:20:09.514626Z","ts":"2026-10-18T15:00:00Z","val":"foo"}`, string(d))
+}
+
+func TestKeepOriginalSchema(t *testing.T) {
+	in, e := avro.Init("../input/avro/input_test.avro", "bar", "2006-01-02T15:04:05.999999")
+	assert.NoError(t, e)
+	defer in.Close()
+	r := Init(in.Codec(), "bar", "2006-01-02T15:04:05.999999", 0)
+
+	assert.Error(t, r.KeepOriginal("orig_ts", ""))
+	assert.NoError(t, r.KeepOriginal("foo", ""))
+	assert.NoError(t, r.KeepOriginal("", "orig"))
+}
+
+func TestRewriteErrors(t *testing.T) {
+	r := Init(input.JSONCodec{}, "non_existent_column", testTSFormat, 0)
+
+	_, _, e := r.Rewrite([]byte(testPayload), testNow)
+	assert.Error(t, e)
+
+	_, _, e = r.Rewrite([]byte("not json"), testNow)
+	assert.Error(t, e)
+}
+
+func TestWrap(t *testing.T) {
+	r := Init(input.JSONCodec{}, testColumn, testTSFormat, 0)
+	assert.NoError(t, r.KeepOriginal("", "orig"))
+
+	var got *output.Message
+	s := r.Wrap(output.SinkFunc(func(ctx context.Context, m *output.Message) error {
+		got = m
+		return nil
+	}))
+
+	e := s.Publish(context.Background(), &output.Message{
+		Tag:        "no=1",
+		Data:       []byte(testPayload),
+		Attributes: map[string]string{"foo": "bar", "orig": "overwritten"},
+	})
+
+	assert.NoError(t, e)
+	assert.Equal(t, "no=1", got.Tag)
+	assert.NotEqual(t, testPayload, string(got.Data))
+	assert.Equal(t, map[string]string{"foo": "bar", "orig": "2019-02-11T15:20:09.514626Z"}, got.Attributes)
+
+	e = s.Publish(context.Background(), &output.Message{Data: []byte(testPayload), Timestamp: testNow})
+
+	assert.NoError(t, e)
+	assert.Equal(t, `{"ts":"2026-10-18T15:00:00Z","val":"foo"}`, string(got.Data))
+
+	e = s.Publish(context.Background(), &output.Message{Data: []byte("not json")})
+
+	assert.Error(t, e)
+}
+
+// testAsyncSink records the messages published asynchronously.
+type testAsyncSink struct {
+	output.SinkFunc
+	got *output.Message
+}
+
+func (s *testAsyncSink) PublishAsync(_ context.Context, m *output.Message, done func(error)) {
+	s.got = m
+	done(nil)
+}
+
+func TestWrapAsync(t *testing.T) {
+	r := Init(input.JSONCodec{}, testColumn, testTSFormat, 0)
+	a := new(testAsyncSink)
+
+	s, ok := r.Wrap(a).(output.AsyncSink)
+
+	assert.True(t, ok)
+	var errs []error
+	s.PublishAsync(context.Background(), &output.Message{Data: []byte(testPayload), Timestamp: testNow}, func(e error) {
+		errs = append(errs, e)
+	})
+	s.PublishAsync(context.Background(), &output.Message{Data: []byte("not json")}, func(e error) {
+		errs = append(errs, e)
+	})
+
+	assert.Equal(t, `{"ts":"2026-10-18T15:00:00Z","val":"foo"}`, string(a.got.Data))
+	assert.Len(t, errs, 2)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+}
@@ -0,0 +1,154 @@
+package runner
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/util"
+)
+
+// ID field mutation modes for amplified copies.
+const (
+	SuffixID = "suffix"
+	UUIDID   = "uuid"
+)
+
+// NumericIDOffset is added to numeric IDs once per copy in suffix mode, e.g.
+// the ID 42 becomes 1000000042 in the second copy.
+const NumericIDOffset = 1000000000
+
+// Amplify wraps the action function so that it is spawned the given number of
+// times for every input record. All copies are spawned concurrently, hence
+// the timing of the original playback is kept. Copies after the first one are
+// passed through an optional mutation function (can be nil), which receives
+// the copy number starting from 1. Copies failing the mutation are skipped.
+// The returned function blocks until all copies are completed.
+func Amplify(action func(string, []byte), n uint, mutate func([]byte, uint) ([]byte, error)) func(string, []byte) {
+	return func(tag string, d []byte) {
+		var wg sync.WaitGroup
+		for i := uint(1); i <= n; i++ {
+			cd := d
+			if i > 1 && mutate != nil {
+				var e error
+				if cd, e = mutate(d, i); e != nil {
+					log.Printf("Error mutating copy %d: %s (%s)", i, e, tag)
+					continue
+				}
+			}
+			wg.Add(1)
+			go func(i uint, d []byte) {
+				action(fmt.Sprintf("%s copy=%d", tag, i), d)
+				wg.Done()
+			}(i, cd)
+		}
+		wg.Wait()
+	}
+}
+
+// IDMutator returns a mutation function for Amplify that makes copies distinct
+// by updating the ID field at the given path. In suffix mode, the copy number
+// is appended to string values, e.g. "foo-2", and numeric values are offset by
+// NumericIDOffset per copy, keeping the original type. Other types are
+// rejected. In uuid mode, the value is replaced with a random UUID derived from
+// the hash of the given seed, the record data and the copy number, so that a
+// seeded playback produces the same UUIDs regardless of the order in
+// which concurrent copies are mutated.
+func IDMutator(c input.Codec, path string, mode string, seed int64) (func([]byte, uint) ([]byte, error), error) {
+	var f func(v interface{}, d []byte, i uint) (interface{}, error)
+	switch mode {
+	case SuffixID:
+		f = func(v interface{}, _ []byte, i uint) (interface{}, error) {
+			return suffixID(v, i)
+		}
+	case UUIDID:
+		f = func(_ interface{}, d []byte, i uint) (interface{}, error) {
+			return copyUUID(seed, d, i), nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown id mutation mode %q", mode)
+	}
+	return func(d []byte, i uint) ([]byte, error) {
+		rec, e := c.Decode(d)
+		if e != nil {
+			return nil, e
+		}
+		m, k := input.Parent(rec, path)
+		if m == nil {
+			return nil, fmt.Errorf("id field %q not found", path)
+		}
+		v, found := m[k]
+		if !found {
+			return nil, fmt.Errorf("id field %q not found", path)
+		}
+		if inner, t, ok := input.Unwrap(v); ok && input.IsPrimitiveUnion(v) {
+			if inner, e = f(inner, d, i); e != nil {
+				return nil, e
+			}
+			m[k] = map[string]interface{}{t: inner}
+		} else if m[k], e = f(v, d, i); e != nil {
+			return nil, e
+		}
+		return c.Encode(rec)
+	}, nil
+}
+
+// suffixID returns the ID value for the given copy in suffix mode.
+func suffixID(v interface{}, i uint) (interface{}, error) {
+	off := int64(i-1) * NumericIDOffset
+	switch id := v.(type) {
+	case string:
+		return fmt.Sprintf("%s-%d", id, i), nil
+	case json.Number:
+		n, e := id.Int64()
+		if e != nil {
+			return nil, fmt.Errorf("id value %s is not an integer", id)
+		}
+		return json.Number(strconv.FormatInt(n+off, 10)), nil
+	case float64:
+		return id + float64(off), nil
+	case int:
+		return id + int(off), nil
+	case int32:
+		n := int64(id) + off
+		if n > math.MaxInt32 {
+			return nil, fmt.Errorf("id value %d overflows the int type for copy %d", id, i)
+		}
+		return int32(n), nil
+	case int64:
+		return id + off, nil
+	default:
+		return nil, fmt.Errorf("suffix id mode requires a string or an integer id, got %T", v)
+	}
+}
+
+// copyUUID returns a UUID for the copy of the record, derived from the hash of
+// the seed, the record data and the copy number. The hash is expanded into
+// the UUID bytes with two splitmix64 steps.
+func copyUUID(seed int64, d []byte, i uint) string {
+	h := fnv.New64a()
+	_ = binary.Write(h, binary.LittleEndian, seed)
+	_, _ = h.Write(d)
+	_ = binary.Write(h, binary.LittleEndian, uint64(i))
+	x := h.Sum64()
+	var b [16]byte
+	binary.LittleEndian.PutUint64(b[:8], splitmix64(&x))
+	binary.LittleEndian.PutUint64(b[8:], splitmix64(&x))
+	return util.FormatUUID(b)
+}
+
+// splitmix64 advances the state and returns the next number of the splitmix64
+// sequence.
+func splitmix64(x *uint64) uint64 {
+	*x += 0x9e3779b97f4a7c15
+	z := *x
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/pburakov/playback/input"
+)
+
+func TestAmplify(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+
+	action := Amplify(func(tag string, d []byte) {
+		mu.Lock()
+		got = append(got, tag+" "+string(d))
+		mu.Unlock()
+	}, 3, func(d []byte, i uint) ([]byte, error) {
+		return append(d, byte('0'+i)), nil
+	})
+	action("no=1", []byte("foo"))
+
+	sort.Strings(got)
+	expected := []string{"no=1 copy=1 foo", "no=1 copy=2 foo2", "no=1 copy=3 foo3"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d copies, got %v", len(expected), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], got[i])
+		}
+	}
+}
+
+func TestIDMutator(t *testing.T) {
+	m, e := IDMutator(input.JSONCodec{}, "user.id", SuffixID, 1)
+	if e != nil {
+		t.Fatal(e)
+	}
+	d, e := m([]byte(`{"user":{"id":"foo"}}`), 2)
+	if e != nil || string(d) != `{"user":{"id":"foo-2"}}` {
+		t.Errorf("unexpected suffix mutation result %q (%v)", d, e)
+	}
+
+	m, _ = IDMutator(input.JSONCodec{}, "id", SuffixID, 1)
+	d, e = m([]byte(`{"id":42}`), 3)
+	if e != nil || string(d) != `{"id":2000000042}` {
+		t.Errorf("unexpected numeric suffix mutation result %q (%v)", d, e)
+	}
+	if _, e := m([]byte(`{"id":true}`), 2); e == nil {
+		t.Error("expected unsupported id type error")
+	}
+
+	m, _ = IDMutator(input.JSONCodec{}, "id", UUIDID, 1)
+	d1, _ := m([]byte(`{"id":"foo"}`), 2)
+	d2, _ := m([]byte(`{"id":"foo"}`), 3)
+	if string(d1) == string(d2) || len(d1) != len(`{"id":"00000000-0000-0000-0000-000000000000"}`) {
+		t.Errorf("unexpected uuid mutation results %q, %q", d1, d2)
+	}
+	// the same seed produces the same UUIDs
+	m, _ = IDMutator(input.JSONCodec{}, "id", UUIDID, 1)
+	if d, _ := m([]byte(`{"id":"foo"}`), 2); string(d) != string(d1) {
+		t.Errorf("expected the same uuid for the same seed, got %q and %q", d1, d)
+	}
+	m, _ = IDMutator(input.JSONCodec{}, "id", UUIDID, 2)
+	if d, _ := m([]byte(`{"id":"foo"}`), 2); string(d) == string(d1) {
+		t.Errorf("expected a different uuid for a different seed, got %q", d)
+	}
+
+	if _, e := m([]byte(`{"foo":"bar"}`), 2); e == nil {
+		t.Error("expected missing field error")
+	}
+	if _, e := IDMutator(input.JSONCodec{}, "id", "foo", 1); e == nil {
+		t.Error("expected unknown mode error")
+	}
+}
+
+func TestSuffixID(t *testing.T) {
+	for _, c := range []struct {
+		v, expected interface{}
+	}{
+		{"foo", "foo-2"},
+		{int32(42), int32(1000000042)},
+		{int64(42), int64(1000000042)},
+		{42, 1000000042},
+		{42.0, 1000000042.0},
+	} {
+		if got, e := suffixID(c.v, 2); e != nil || got != c.expected {
+			t.Errorf("expected %#v for %#v, got %#v (%v)", c.expected, c.v, got, e)
+		}
+	}
+	if _, e := suffixID(int32(42), 4); e == nil {
+		t.Error("expected int overflow error")
+	}
+	if _, e := suffixID(json.Number("4.2"), 2); e == nil {
+		t.Error("expected non-integer id error")
+	}
+}
@@ -1,75 +1,174 @@
 package runner
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"sync"
 	"time"
 
+	"github.com/pburakov/playback/clock"
 	"github.com/pburakov/playback/input"
 	"github.com/pburakov/playback/util"
 )
 
+// profileTick is the rate integration interval of the profiled playback.
+const profileTick = 10 * time.Millisecond
+
+// Runner holds the clock and the random source used for scheduling the
+// playback. Runner is not safe for concurrent playbacks, since the random
+// source isn't synchronized.
+type Runner struct {
+	clock  clock.Clock
+	rand   *rand.Rand
+	anchor *anchor
+	start  time.Time
+	// skip makes the relative playback jump over the empty windows, which is
+	// only meaningful with a virtual clock.
+	skip bool
+}
+
+// anchor maps an event timestamp to a wall-clock time.
+type anchor struct {
+	ts time.Time
+	at time.Time
+}
+
+// New returns a runner using the given clock and random source.
+func New(c clock.Clock, r *rand.Rand) *Runner {
+	return &Runner{clock: c, rand: r}
+}
+
+// Default returns a runner using the wall clock and a time-seeded random source.
+func Default() *Runner {
+	return New(clock.Real, rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+// Anchor makes the relative and simulated playback map the given event
+// timestamp to the given wall-clock time, instead of mapping the first record
+// timestamp to the playback start. Anchoring several runners to the same point
+// aligns their playback timelines.
+func (r *Runner) Anchor(ts time.Time, at time.Time) {
+	r.anchor = &anchor{ts: ts, at: at}
+}
+
+// StartAt makes the playback wait until the runner clock reaches the given
+// time before reading the input. Zero time means no wait.
+func (r *Runner) StartAt(t time.Time) {
+	r.start = t
+}
+
 // PlayRelative sets the window boundary to a lookahead duration value, reads the
 // data from the input file line by line into memory and spawns the given action on
 // the input data. The process is repeated until the EOF is met, or until the first
 // timestamp outside the boundary is found. The thread then waits until the runtime
 // clock is also outside the boundary (adjusted for an arbitrary jitter), shifts
 // the boundary forward by the lookahead duration value and repeats.
-// This method blocks until all lines and all spawned actions are completed.
+// This method blocks until all lines and all spawned actions are completed, or
+// until the context is cancelled. Reader errors stop the playback and are returned.
 //
-// The parameters are the input reader implementation, action function, lookahead
-// duration value and a maximum jitter setting (in milliseconds).
-func PlayRelative(in input.FileReader, action func(string, []byte), lh time.Duration, mjMSec int) {
-	delta := time.Duration(0)
-	boundary := time.Now().Add(lh)
+// The parameters are the context, the input reader implementation, action function,
+// lookahead duration value and a maximum jitter setting (in milliseconds).
+func (r *Runner) PlayRelative(ctx context.Context, in input.FileReader, action func(string, []byte), lh time.Duration, mjMSec int) error {
+	return r.playRelative(ctx, in, func(_ time.Time, tag string, d []byte) {
+		action(tag, d)
+	}, lh, mjMSec)
+}
+
+// PlaySimulated runs the relative playback scheduling against a virtual clock
+// starting at the current time (or the start time, if later), so that the
+// input data is spawned without waiting. The virtual clock jumps straight to
+// the window of the next record, skipping the empty windows. The given action
+// receives the intended send time of the message, i.e. its timestamp shifted
+// onto the playback timeline. The virtual clock is set on the reader, if it
+// produces time-dependent data.
+// This method blocks until all lines and all spawned actions are completed, or
+// until the context is cancelled. Reader errors stop the playback and are returned.
+//
+// The parameters are the context, the input reader implementation, action function,
+// lookahead duration value and a maximum jitter setting (in milliseconds).
+func (r *Runner) PlaySimulated(ctx context.Context, in input.FileReader, action func(time.Time, string, []byte), lh time.Duration, mjMSec int) error {
+	v := New(clock.NewVirtual(r.clock.Now()), r.rand)
+	v.anchor, v.start, v.skip = r.anchor, r.start, true
+	input.SetClock(in, v.clock)
+	return v.playRelative(ctx, in, action, lh, mjMSec)
+}
+
+// playRelative implements the relative playback. The action receives the
+// record timestamp shifted onto the playback timeline.
+func (r *Runner) playRelative(ctx context.Context, in input.FileReader, action func(time.Time, string, []byte), lh time.Duration, mjMSec int) error {
+	if e := r.WaitUntil(ctx, r.start); e != nil {
+		return e
+	}
+	var delta time.Duration
+	first := true
+	boundary := r.clock.Now().Add(lh)
 
 	log.Printf("Lookahead duration is %q with max jitter %q", lh, util.MSecToDuration(mjMSec))
 
 	var wg sync.WaitGroup
+	defer wg.Wait()
 
 	for {
 		ts, d, e := in.ReadLineWithTS()
 		if e == io.EOF {
-			break
+			return nil
 		}
 		if e != nil {
-			util.Fatal(e)
-			return
+			return e
 		}
-		if delta == 0 {
-			delta = time.Now().Sub(ts)
+		if first {
+			first = false
+			if r.anchor != nil {
+				delta = r.anchor.at.Sub(r.anchor.ts)
+			} else {
+				delta = r.clock.Now().Sub(ts)
+			}
 			log.Printf("First timestamp is %s (delta vs now is %s)", ts, delta)
 		}
 		adjustedTS := ts.Add(delta)
 
 		for adjustedTS.After(boundary) {
-			jitter := util.Jitter(mjMSec)
+			jitter := util.Jitter(r.rand, mjMSec)
 			// wait until we're outside the window boundary + jitter
-			<-time.After(boundary.Add(jitter).Sub(time.Now()))
-			boundary = time.Now().Add(lh)
+			wait := boundary.Add(jitter).Sub(r.clock.Now())
+			if r.skip {
+				// or right until the record gets within the lookahead window
+				if w := adjustedTS.Add(-lh).Sub(r.clock.Now()); w > wait {
+					wait = w
+				}
+			}
+			if e := r.sleep(ctx, wait); e != nil {
+				return e
+			}
+			boundary = r.clock.Now().Add(lh)
 		}
 
 		wg.Add(1)
-		go func(t time.Time, d []byte) {
-			action("timestamp="+t.String(), d)
+		go func(at, t time.Time, d []byte) {
+			action(at, "timestamp="+t.String(), d)
 			wg.Done()
-		}(ts, d)
+		}(adjustedTS, ts, d)
 	}
-	wg.Wait()
 }
 
 // PlayPaced reads the data from the input file line by line into memory
 // and spawns the given action on the input data at a given rate until the EOF
 // is met. The pacing is achieved by waiting the given delay duration
 // between reads.
-// This method blocks until all lines and all spawned actions are completed.
+// This method blocks until all lines and all spawned actions are completed, or
+// until the context is cancelled. Reader errors stop the playback and are returned.
 //
-// The parameters are the input reader implementation, action function, delay
-// duration value and a maximum jitter setting (in milliseconds).
-func PlayPaced(in input.FileReader, action func(string, []byte), del time.Duration, mjMSec int) {
+// The parameters are the context, the input reader implementation, action function,
+// delay duration value and a maximum jitter setting (in milliseconds).
+func (r *Runner) PlayPaced(ctx context.Context, in input.FileReader, action func(string, []byte), del time.Duration, mjMSec int) error {
+	if e := r.WaitUntil(ctx, r.start); e != nil {
+		return e
+	}
 	var wg sync.WaitGroup
+	defer wg.Wait()
 	var i uint64 = 0
 
 	log.Printf("Base delay between messages is %s with max jitter %s",
@@ -78,11 +177,10 @@ func PlayPaced(in input.FileReader, action func(string, []byte), del time.Durati
 	for {
 		d, e := in.ReadLine()
 		if e == io.EOF {
-			break
+			return nil
 		}
 		if e != nil {
-			util.Fatal(e)
-			return
+			return e
 		}
 
 		wg.Add(1)
@@ -92,31 +190,132 @@ func PlayPaced(in input.FileReader, action func(string, []byte), del time.Durati
 			wg.Done()
 		}(i, d)
 
-		jitter := util.Jitter(mjMSec)
-		time.Sleep(time.Duration(jitter.Nanoseconds() + del.Nanoseconds()))
+		jitter := util.Jitter(r.rand, mjMSec)
+		if e := r.sleep(ctx, time.Duration(jitter.Nanoseconds()+del.Nanoseconds())); e != nil {
+			return e
+		}
 	}
-	wg.Wait()
 }
 
 // PlayInstant attempts to read all the data from the input file line by
 // line and spawn the given action on the input data. No throttling of limiting
 // is implemented, hence the performance of this method is limited by the IO
 // constraints, allocated memory and available lCPU.
-// This method blocks until all lines and all spawned actions are completed.
+// This method blocks until all lines and all spawned actions are completed, or
+// until the context is cancelled. Reader errors stop the playback and are returned.
+//
+// The parameters are the context, the input reader implementation and action function.
+func (r *Runner) PlayInstant(ctx context.Context, in input.FileReader, action func(string, []byte)) error {
+	if e := r.WaitUntil(ctx, r.start); e != nil {
+		return e
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	var i uint64 = 0
+
+	for {
+		if e := ctx.Err(); e != nil {
+			return e
+		}
+		d, e := in.ReadLine()
+		if e == io.EOF {
+			return nil
+		}
+		if e != nil {
+			return e
+		}
+
+		wg.Add(1)
+		i++
+		go func(i uint64, d []byte) {
+			action(fmt.Sprintf("no=%d", i), d)
+			wg.Done()
+		}(i, d)
+	}
+}
+
+// PlayProfile reads the data from the input file line by line into memory and
+// spawns the given action on the input data at a variable rate, until the EOF
+// is met. The rate function returns the target rate in messages per second
+// for the time elapsed since the start of the playback. The rate is integrated
+// over short ticks, and the messages due within a tick are spawned at once.
+// This method blocks until all lines and all spawned actions are completed, or
+// until the context is cancelled. Reader errors stop the playback and are returned.
+//
+// The parameters are the context, the input reader implementation, action
+// function and rate function.
+func (r *Runner) PlayProfile(ctx context.Context, in input.FileReader, action func(string, []byte), rate func(time.Duration) float64) error {
+	if e := r.WaitUntil(ctx, r.start); e != nil {
+		return e
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	var i uint64 = 0
+
+	start := r.clock.Now()
+	last := time.Duration(0)
+	credit := 0.0
+
+	for tick := profileTick; ; tick += profileTick {
+		if e := r.sleep(ctx, start.Add(tick).Sub(r.clock.Now())); e != nil {
+			return e
+		}
+
+		elapsed := r.clock.Now().Sub(start)
+		credit += rate(last) * (elapsed - last).Seconds()
+		last = elapsed
+
+		for ; credit >= 1; credit-- {
+			d, e := in.ReadLine()
+			if e == io.EOF {
+				return nil
+			}
+			if e != nil {
+				return e
+			}
+
+			wg.Add(1)
+			i++
+			go func(i uint64, d []byte) {
+				action(fmt.Sprintf("no=%d", i), d)
+				wg.Done()
+			}(i, d)
+		}
+	}
+}
+
+// PlayStatistical reads the data from the input file line by line into memory
+// and spawns the given action on the input data until the EOF is met. Gaps
+// between consecutive messages are drawn from the given gap function, e.g. a
+// random distribution, which receives the random source of the runner. Send
+// times are scheduled on an absolute timeline, so that the scheduling overhead
+// doesn't skew the mean rate.
+// This method blocks until all lines and all spawned actions are completed, or
+// until the context is cancelled. Reader errors stop the playback and are returned.
 //
-// The parameters are the input reader implementation and action function.
-func PlayInstant(in input.FileReader, action func(string, []byte)) {
+// The parameters are the context, the input reader implementation, action
+// function and gap function.
+func (r *Runner) PlayStatistical(ctx context.Context, in input.FileReader, action func(string, []byte), gap func(*rand.Rand) time.Duration) error {
+	if e := r.WaitUntil(ctx, r.start); e != nil {
+		return e
+	}
 	var wg sync.WaitGroup
+	defer wg.Wait()
 	var i uint64 = 0
 
+	next := r.clock.Now()
+
 	for {
 		d, e := in.ReadLine()
 		if e == io.EOF {
-			break
+			return nil
 		}
 		if e != nil {
-			util.Fatal(e)
-			return
+			return e
+		}
+
+		if e := r.sleep(ctx, next.Sub(r.clock.Now())); e != nil {
+			return e
 		}
 
 		wg.Add(1)
@@ -125,6 +324,30 @@ func PlayInstant(in input.FileReader, action func(string, []byte)) {
 			action(fmt.Sprintf("no=%d", i), d)
 			wg.Done()
 		}(i, d)
+
+		next = next.Add(gap(r.rand))
+	}
+}
+
+// WaitUntil pauses until the runner clock reaches the given time, or until the
+// context is cancelled, in which case the context error is returned. Past
+// times don't pause.
+func (r *Runner) WaitUntil(ctx context.Context, t time.Time) error {
+	return r.sleep(ctx, t.Sub(r.clock.Now()))
+}
+
+// sleep pauses for the given duration, or until the context is cancelled, in
+// which case the context error is returned.
+func (r *Runner) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := r.clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	wg.Wait()
 }
@@ -1,12 +1,20 @@
-// A suite of basic end-to-end tests to verify workflows
+// A suite of basic end-to-end tests to verify workflows. Tests run against a
+// fake clock with a fixed seed.
 package runner
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"math/rand"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/pburakov/playback/clock"
 	"github.com/pburakov/playback/input"
 )
 
@@ -17,53 +25,280 @@ const (
 	testWindow       = 100 * time.Millisecond
 	testDelay        = 500 * time.Millisecond
 	testJitter       = 50
+	testSeed         = 1
 )
 
 func TestPlayInstant(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
 	success := make(chan bool, 1)
 
-	in := initTestReader(t)
-	PlayInstant(in, testOutput(expectedPayload, success))
-	waitForSuccess(t, success)
+	play(t, c, 0, func() error {
+		return testRunner(c).PlayInstant(context.Background(), initTestReader(t), testOutput(expectedPayload, success))
+	})
+
+	expectSuccess(t, success)
 }
 
 func TestPlayRelative(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
 	success := make(chan bool, 1)
 
-	in := initTestReader(t)
-	PlayRelative(in, testOutput(expectedPayload, success), testWindow, testJitter)
-	waitForSuccess(t, success)
+	play(t, c, testWindow, func() error {
+		return testRunner(c).PlayRelative(context.Background(), initTestReader(t), testOutput(expectedPayload, success), testWindow, testJitter)
+	})
+
+	expectSuccess(t, success)
 }
 
 func TestPlayPaced(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := clock.NewFake(start)
 	success := make(chan bool, 1)
 
-	in := initTestReader(t)
-	PlayPaced(in, testOutput(expectedPayload, success), testDelay, testJitter)
-	waitForSuccess(t, success)
+	play(t, c, time.Millisecond, func() error {
+		return testRunner(c).PlayPaced(context.Background(), initTestReader(t), testOutput(expectedPayload, success), testDelay, testJitter)
+	})
+
+	expectSuccess(t, success)
+	// the runner waits for the delay after the message, adjusted for jitter
+	elapsed := c.Now().Sub(start)
+	if elapsed < testDelay-testJitter*time.Millisecond || elapsed > testDelay+testJitter*time.Millisecond {
+		t.Errorf("unexpected delay %s", elapsed)
+	}
 }
 
-func testOutput(expected string, success chan bool) func(string, []byte) {
-	return func(s string, b []byte) {
-		if string(b) == expected {
-			log.Printf("published test message (%s)", s)
-			success <- true
+func TestPlayProfile(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := clock.NewFake(start)
+	sent := make(chan time.Time, 1)
+
+	play(t, c, profileTick, func() error {
+		return testRunner(c).PlayProfile(context.Background(), initTestReader(t), func(string, []byte) {
+			sent <- c.Now()
+		}, func(time.Duration) float64 {
+			return 10
+		})
+	})
+
+	// the first message is due after 100ms at 10 msg/s
+	at := <-sent
+	if elapsed := at.Sub(start); elapsed < 100*time.Millisecond || elapsed > 100*time.Millisecond+profileTick {
+		t.Errorf("unexpected time of the first message %s", elapsed)
+	}
+}
+
+func TestPlayStatistical(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := clock.NewFake(start)
+	var got []time.Time
+	var mu sync.Mutex
+
+	play(t, c, time.Millisecond, func() error {
+		return testRunner(c).PlayStatistical(context.Background(), &countReader{n: 3}, func(string, []byte) {
+			mu.Lock()
+			got = append(got, c.Now())
+			mu.Unlock()
+		}, func(r *rand.Rand) time.Duration {
+			return time.Duration(r.Intn(10)+1) * time.Millisecond
+		})
+	})
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(got))
+	}
+	// gaps are drawn from the seeded source of the runner
+	sort.Slice(got, func(i, j int) bool { return got[i].Before(got[j]) })
+	r := rand.New(rand.NewSource(testSeed))
+	expected := start
+	for i := range got {
+		if !got[i].Equal(expected) {
+			t.Errorf("expected message %d at %s, got %s", i+1, expected, got[i])
+		}
+		expected = expected.Add(time.Duration(r.Intn(10)+1) * time.Millisecond)
+	}
+}
+
+func TestPlayPacedFakeClock(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	r := New(c, rand.New(rand.NewSource(1)))
+	in := &countReader{n: 3}
+	sent := make(chan string, 3)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- r.PlayPaced(context.Background(), in, func(tag string, _ []byte) { sent <- tag }, time.Second, 0)
+	}()
+
+	for i := 1; i <= 3; i++ {
+		c.BlockUntil(1)
+		if tag := <-sent; tag != fmt.Sprintf("no=%d", i) {
+			t.Errorf("unexpected tag %q", tag)
 		}
+		if l := len(sent); l != 0 {
+			t.Errorf("expected no more messages before the clock advances, got %d", l)
+		}
+		c.Advance(time.Second)
+	}
+	if e := <-done; e != nil {
+		t.Error(e)
+	}
+}
+
+func TestPlaySimulated(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := clock.NewFake(start)
+	r := New(c, rand.New(rand.NewSource(1)))
+	in := &countReader{n: 3, gap: time.Hour}
+
+	var mu sync.Mutex
+	var got []time.Time
+	e := r.PlaySimulated(context.Background(), in, func(at time.Time, _ string, _ []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, at)
+	}, time.Second, 0)
+	if e != nil {
+		t.Error(e)
+	}
+
+	sort.Slice(got, func(i, j int) bool { return got[i].Before(got[j]) })
+	expected := []time.Time{start, start.Add(time.Hour), start.Add(2 * time.Hour)}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d messages, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		if !got[i].Equal(expected[i]) {
+			t.Errorf("expected intended time %s, got %s", expected[i], got[i])
+		}
+	}
+	if !c.Now().Equal(start) {
+		t.Errorf("runner clock must not move, got %s", c.Now())
+	}
+}
+
+func TestPlayAnchored(t *testing.T) {
+	start := time.Unix(0, 0)
+	r := New(clock.NewFake(start), rand.New(rand.NewSource(1)))
+	// the first record is an hour after the anchored timestamp
+	r.Anchor(start.Add(-time.Hour), start)
+
+	var got time.Time
+	e := r.PlaySimulated(context.Background(), &countReader{n: 1}, func(at time.Time, _ string, _ []byte) {
+		got = at
+	}, time.Second, 0)
+	if e != nil {
+		t.Error(e)
+	}
+	if expected := start.Add(time.Hour); !got.Equal(expected) {
+		t.Errorf("expected intended time %s, got %s", expected, got)
+	}
+}
+
+func TestPlayStartAt(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := clock.NewFake(start)
+	r := New(c, rand.New(rand.NewSource(1)))
+	r.StartAt(start.Add(time.Hour))
+
+	sent := make(chan time.Time, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- r.PlayInstant(context.Background(), &countReader{n: 1}, func(string, []byte) { sent <- c.Now() })
+	}()
+
+	c.BlockUntil(1)
+	if l := len(sent); l != 0 {
+		t.Errorf("expected no messages before the start time, got %d", l)
+	}
+	c.Advance(time.Hour)
+	if e := <-done; e != nil {
+		t.Error(e)
+	}
+	if at := <-sent; !at.Equal(start.Add(time.Hour)) {
+		t.Errorf("expected message at the start time, got %s", at)
+	}
+
+	// simulated playback moves the virtual clock to the start time
+	r = New(clock.NewFake(start), rand.New(rand.NewSource(1)))
+	r.StartAt(start.Add(time.Hour))
+	var got time.Time
+	e := r.PlaySimulated(context.Background(), &countReader{n: 1}, func(at time.Time, _ string, _ []byte) {
+		got = at
+	}, time.Second, 0)
+	if e != nil {
+		t.Error(e)
+	}
+	if expected := start.Add(time.Hour); !got.Equal(expected) {
+		t.Errorf("expected intended time %s, got %s", expected, got)
 	}
 }
 
-// waitForSuccess waits up to 5 seconds for delivery
-func waitForSuccess(t *testing.T, success chan bool) {
-	for i := 0; i < 5; i++ {
+func TestPlayCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	in := initTestReader(t)
+	e := Default().PlayPaced(ctx, in, func(string, []byte) {}, time.Hour, testJitter)
+	if e != context.Canceled {
+		t.Errorf("expected context error, got %v", e)
+	}
+}
+
+func TestPlayReaderError(t *testing.T) {
+	expected := errors.New("read error")
+
+	e := Default().PlayInstant(context.Background(), &errorReader{e: expected}, func(string, []byte) {})
+	if e != expected {
+		t.Errorf("expected reader error, got %v", e)
+	}
+}
+
+// testRunner returns a runner using the given clock and a fixed seed.
+func testRunner(c clock.Clock) *Runner {
+	return New(c, rand.New(rand.NewSource(testSeed)))
+}
+
+// play runs the playback in the background and advances the fake clock by the
+// given step whenever the runner waits, until the playback returns.
+func play(t *testing.T, c *clock.Fake, step time.Duration, f func() error) {
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	for {
 		select {
-		case <-success:
+		case e := <-done:
+			if e != nil {
+				t.Error(e)
+			}
 			return
-		case <-time.After(1 * time.Second):
-			continue
+		default:
+		}
+		if c.Waiters() > 0 {
+			c.Advance(step)
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// expectSuccess checks that the test message was published. The playback
+// returns once all spawned actions are completed.
+func expectSuccess(t *testing.T, success chan bool) {
+	select {
+	case <-success:
+	default:
+		t.Error("test message was not published")
+	}
+}
+
+func testOutput(expected string, success chan bool) func(string, []byte) {
+	return func(s string, b []byte) {
+		if string(b) == expected {
+			log.Printf("published test message (%s)", s)
+			success <- true
 		}
 	}
-	t.Log("time out waiting for success")
-	t.Fail()
 }
 
 type testReader struct {
@@ -100,3 +335,39 @@ func (r *testReader) ReadLine() (data []byte, e error) {
 		return nil, io.EOF
 	}
 }
+
+type countReader struct {
+	n   int
+	gap time.Duration
+	ts  time.Time
+}
+
+func (r *countReader) ReadLineWithTS() (ts time.Time, data []byte, e error) {
+	if r.ts.IsZero() {
+		r.ts = time.Unix(0, 0)
+	} else {
+		r.ts = r.ts.Add(r.gap)
+	}
+	d, e := r.ReadLine()
+	return r.ts, d, e
+}
+
+func (r *countReader) ReadLine() (data []byte, e error) {
+	if r.n == 0 {
+		return nil, io.EOF
+	}
+	r.n--
+	return []byte(expectedPayload), nil
+}
+
+type errorReader struct {
+	e error
+}
+
+func (r *errorReader) ReadLineWithTS() (ts time.Time, data []byte, e error) {
+	return time.Now(), nil, r.e
+}
+
+func (r *errorReader) ReadLine() (data []byte, e error) {
+	return nil, r.e
+}
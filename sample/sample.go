@@ -0,0 +1,119 @@
+package sample
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/pburakov/playback/clock"
+	"github.com/pburakov/playback/input"
+)
+
+// Reader passes through a fraction of the records of the underlying reader.
+// Records are either sampled randomly, or deterministically by the hash of
+// the key field value, so that all records sharing the same key are either
+// kept or skipped, preserving per-key event sequences.
+type Reader struct {
+	r       input.FileReader
+	rate    float64
+	key     string
+	c       input.Codec
+	rand    *rand.Rand
+	skipped uint64
+}
+
+var _ input.FileReader = (*Reader)(nil)
+var _ input.CodecProvider = (*Reader)(nil)
+var _ input.Clocked = (*Reader)(nil)
+
+// Init returns a sampling reader keeping the given fraction of the records.
+// If the key is set, records are sampled by the key field value and the
+// reader must provide a codec for its data. Otherwise, records are sampled
+// using the given random source.
+func Init(r input.FileReader, rate float64, key string, rnd *rand.Rand) (*Reader, error) {
+	if rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("invalid sampling rate %v, expected value between 0 and 1", rate)
+	}
+	s := &Reader{r: r, rate: rate, key: key, rand: rnd}
+	if p, ok := r.(input.CodecProvider); ok {
+		s.c = p.Codec()
+	}
+	if len(key) > 0 && s.c == nil {
+		return nil, errors.New("sampling by key is not supported by the reader")
+	}
+	return s, nil
+}
+
+func (s *Reader) ReadLineWithTS() (ts time.Time, data []byte, e error) {
+	for {
+		ts, data, e = s.r.ReadLineWithTS()
+		if e != nil {
+			return ts, nil, e
+		}
+		if ok, e := s.keep(data); e != nil || ok {
+			return ts, data, e
+		}
+	}
+}
+
+func (s *Reader) ReadLine() (data []byte, e error) {
+	for {
+		data, e = s.r.ReadLine()
+		if e != nil {
+			return nil, e
+		}
+		if ok, e := s.keep(data); e != nil || ok {
+			return data, e
+		}
+	}
+}
+
+// Close closes the underlying reader.
+func (s *Reader) Close() error {
+	return input.Close(s.r)
+}
+
+func (s *Reader) Codec() input.Codec {
+	return s.c
+}
+
+// SetClock sets the clock of the underlying reader.
+func (s *Reader) SetClock(c clock.Clock) {
+	input.SetClock(s.r, c)
+}
+
+// Skipped returns the number of records skipped so far.
+func (s *Reader) Skipped() uint64 {
+	return atomic.LoadUint64(&s.skipped)
+}
+
+// keep decides whether the record is kept.
+func (s *Reader) keep(d []byte) (bool, error) {
+	var p float64
+	if len(s.key) == 0 {
+		p = s.rand.Float64()
+	} else {
+		rec, e := s.c.Decode(d)
+		if e != nil {
+			return false, e
+		}
+		v, _ := input.Lookup(rec, s.key)
+		p = Fraction(fmt.Sprint(v))
+	}
+	if p < s.rate {
+		return true, nil
+	}
+	atomic.AddUint64(&s.skipped, 1)
+	return false, nil
+}
+
+// Fraction maps the key to a value in [0, 1) using the SHA-256 hash of the key.
+func Fraction(key string) float64 {
+	h := sha256.Sum256([]byte(key))
+	// Use top 53 bits to fit float64 mantissa
+	return float64(binary.BigEndian.Uint64(h[:8])>>11) / (1 << 53)
+}
@@ -0,0 +1,112 @@
+package sample
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pburakov/playback/input"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRand() *rand.Rand {
+	return rand.New(rand.NewSource(1))
+}
+
+func TestSampleRandom(t *testing.T) {
+	r, e := Init(initTestReader(1000), 0.1, "", testRand())
+
+	assert.NoError(t, e)
+
+	n := count(t, r)
+
+	assert.True(t, n > 0 && n < 500, "sampled %d records", n)
+	assert.Equal(t, uint64(1000-n), r.Skipped())
+
+	// the same seed keeps the same records
+	r, _ = Init(initTestReader(1000), 0.1, "", testRand())
+	assert.Equal(t, n, count(t, r))
+}
+
+func TestSampleByKey(t *testing.T) {
+	r, e := Init(initTestReader(1000), 0.5, "user_id", testRand())
+
+	assert.NoError(t, e)
+
+	kept := make(map[string]bool)
+	for {
+		d, e := r.ReadLine()
+		if e == io.EOF {
+			break
+		}
+		assert.NoError(t, e)
+		rec, _ := input.JSONCodec{}.Decode(d)
+		kept[rec["user_id"].(string)] = true
+	}
+
+	// Every user has 10 events, all of them are kept or skipped together
+	assert.True(t, len(kept) > 0 && len(kept) < 100)
+	assert.Equal(t, uint64((100-len(kept))*10), r.Skipped())
+	for k := range kept {
+		assert.True(t, Fraction(k) < 0.5)
+	}
+}
+
+func TestSampleAll(t *testing.T) {
+	r, _ := Init(initTestReader(100), 1, "user_id", testRand())
+
+	assert.Equal(t, 100, count(t, r))
+
+	r, _ = Init(initTestReader(100), 0, "", testRand())
+
+	assert.Equal(t, 0, count(t, r))
+}
+
+func TestInitErrors(t *testing.T) {
+	_, e := Init(initTestReader(1), 1.1, "", testRand())
+
+	assert.Error(t, e)
+
+	_, e = Init(nil, 0.5, "user_id", testRand())
+
+	assert.Error(t, e)
+}
+
+func count(t *testing.T, r *Reader) int {
+	n := 0
+	for {
+		_, _, e := r.ReadLineWithTS()
+		if e == io.EOF {
+			return n
+		}
+		assert.NoError(t, e)
+		n++
+	}
+}
+
+type testReader struct {
+	n, i int
+}
+
+func initTestReader(n int) *testReader {
+	return &testReader{n: n}
+}
+
+func (r *testReader) ReadLineWithTS() (ts time.Time, data []byte, e error) {
+	d, e := r.ReadLine()
+	return time.Unix(int64(r.i), 0), d, e
+}
+
+func (r *testReader) ReadLine() (data []byte, e error) {
+	if r.i >= r.n {
+		return nil, io.EOF
+	}
+	r.i++
+	return []byte(fmt.Sprintf(`{"user_id":"u%d","seq":%d}`, r.i%100, r.i)), nil
+}
+
+func (r *testReader) Codec() input.Codec {
+	return input.JSONCodec{}
+}
@@ -0,0 +1,44 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// BindContainer starts a test-container of the given image and command,
+// exposing the given TCP port, and returns the address of the mapped port in
+// the form of host:port. The container is ready once the port accepts
+// connections.
+func BindContainer(image string, cmd string, port string) string {
+	ctx := context.Background()
+	p := nat.Port(fmt.Sprintf("%s/tcp", port))
+	req := testcontainers.ContainerRequest{
+		Image:        image,
+		Cmd:          cmd,
+		ExposedPorts: []string{string(p)},
+		WaitingFor:   wait.ForListeningPort(p).WithStartupTimeout(60 * time.Second),
+	}
+
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		panic(err)
+	}
+	mp, err := c.MappedPort(ctx, p)
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%s:%d", host, mp.Int())
+}
@@ -0,0 +1,109 @@
+package transform
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/pburakov/playback/clock"
+	"github.com/pburakov/playback/input"
+)
+
+// Reader applies transformation rules to the records returned by the
+// underlying reader. Records are decoded and encoded back using the codec of
+// the input format. Records missing the fields to hash or redact are counted,
+// and the counts are logged on close.
+type Reader struct {
+	r     input.FileReader
+	c     input.Codec
+	rules *Rules
+
+	// union branches of the values to set, by path
+	branches map[string]string
+	// number of records missing the fields to hash or redact, by path
+	misses map[string]uint64
+}
+
+var _ input.FileReader = (*Reader)(nil)
+var _ input.CodecProvider = (*Reader)(nil)
+var _ input.Clocked = (*Reader)(nil)
+
+// Init returns a transforming reader wrapping the given reader. The reader
+// must provide a codec for its data. With codecs bound to a schema, such as
+// Avro, the rules are checked against the schema, so that the transformed
+// records can be encoded.
+func Init(r input.FileReader, rules *Rules) (*Reader, error) {
+	p, ok := r.(input.CodecProvider)
+	if !ok || p.Codec() == nil {
+		return nil, errors.New("transformations are not supported by the reader")
+	}
+	var branches map[string]string
+	if s, ok := p.Codec().(input.SchemaCodec); ok {
+		var e error
+		if branches, e = checkSchema(s, rules); e != nil {
+			return nil, e
+		}
+	}
+	return &Reader{r: r, c: p.Codec(), rules: rules, branches: branches, misses: make(map[string]uint64)}, nil
+}
+
+func (t *Reader) ReadLineWithTS() (ts time.Time, data []byte, e error) {
+	ts, data, e = t.r.ReadLineWithTS()
+	if e != nil {
+		return ts, nil, e
+	}
+	data, e = t.transform(data)
+	return ts, data, e
+}
+
+func (t *Reader) ReadLine() (data []byte, e error) {
+	data, e = t.r.ReadLine()
+	if e != nil {
+		return nil, e
+	}
+	return t.transform(data)
+}
+
+// Misses returns the number of records missing the given field to hash or
+// redact.
+func (t *Reader) Misses(path string) uint64 {
+	return t.misses[path]
+}
+
+// Close closes the underlying reader.
+func (t *Reader) Close() error {
+	for _, p := range append(append([]string{}, t.rules.Hash...), t.rules.Redact...) {
+		if n := t.misses[p]; n > 0 {
+			log.Printf("Field %q to hash or redact was missing in %d records", p, n)
+		}
+	}
+	return input.Close(t.r)
+}
+
+func (t *Reader) Codec() input.Codec {
+	return t.c
+}
+
+// SetClock sets the clock of the underlying reader.
+func (t *Reader) SetClock(c clock.Clock) {
+	input.SetClock(t.r, c)
+}
+
+// transform decodes the data, applies the rules and encodes the data back.
+func (t *Reader) transform(d []byte) ([]byte, error) {
+	rec, e := t.c.Decode(d)
+	if e != nil {
+		return nil, e
+	}
+	missing, e := t.rules.apply(rec, t.branches)
+	if e != nil {
+		return nil, e
+	}
+	for _, p := range missing {
+		if t.misses[p] == 0 {
+			log.Printf("Field %q to hash or redact is missing in the record, left as is", p)
+		}
+		t.misses[p]++
+	}
+	return t.c.Encode(rec)
+}
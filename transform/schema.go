@@ -0,0 +1,171 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pburakov/playback/input"
+)
+
+// checkSchema checks the rules against the record schema, so that the
+// transformed records can be encoded, and returns the union branches of the
+// values to set, by path.
+func checkSchema(s input.SchemaCodec, r *Rules) (map[string]string, error) {
+	if len(r.Keep) > 0 {
+		for _, p := range r.Keep {
+			if _, e := field(s, p); e != nil {
+				return nil, fmt.Errorf("unable to keep field: %s", e)
+			}
+		}
+		if e := checkKeep(s, "", r.Keep); e != nil {
+			return nil, fmt.Errorf("unable to keep fields: %s", e)
+		}
+	}
+	for _, p := range r.Drop {
+		if e := removable(s, p); e != nil {
+			return nil, fmt.Errorf("unable to drop field: %s", e)
+		}
+	}
+	for _, rn := range r.Rename {
+		f, e := field(s, rn[0])
+		if e == nil {
+			e = removable(s, rn[0])
+		}
+		if e != nil {
+			return nil, fmt.Errorf("unable to rename field: %s", e)
+		}
+		to := rn[1]
+		if i := strings.LastIndex(rn[0], "."); i >= 0 {
+			to = rn[0][:i+1] + rn[1]
+		}
+		t, e := field(s, to)
+		if e != nil {
+			return nil, fmt.Errorf("unable to rename field %q: %s", rn[0], e)
+		}
+		if strings.Join(f.Types, ",") != strings.Join(t.Types, ",") {
+			return nil, fmt.Errorf("unable to rename field %q: field %q is of a different type", rn[0], to)
+		}
+	}
+
+	branches := make(map[string]string)
+	for p, v := range r.Set {
+		f, e := field(s, p)
+		if e != nil {
+			return nil, fmt.Errorf("unable to set field: %s", e)
+		}
+		segs := strings.Split(p, ".")
+		for i := 1; i < len(segs); i++ {
+			parent := strings.Join(segs[:i], ".")
+			if pf, _ := s.Field(parent); pf.Nullable() {
+				return nil, fmt.Errorf("unable to set field %q: parent field %q can be null", p, parent)
+			}
+		}
+		b, ok := branch(literal(v), f.Types)
+		if !ok {
+			return nil, fmt.Errorf("unable to set field %q: value %s doesn't match type %s", p, v, strings.Join(f.Types, "|"))
+		}
+		if len(f.Types) > 1 {
+			branches[p] = b
+		}
+	}
+	for _, p := range r.Hash {
+		f, e := field(s, p)
+		if e != nil {
+			return nil, fmt.Errorf("unable to hash field: %s", e)
+		}
+		for _, t := range f.Types {
+			switch t {
+			case "null", "string", "int", "long", "float", "double":
+			default:
+				return nil, fmt.Errorf("unable to hash field %q of type %s", p, t)
+			}
+		}
+	}
+	for _, p := range r.Redact {
+		f, e := field(s, p)
+		if e != nil {
+			return nil, fmt.Errorf("unable to redact field: %s", e)
+		}
+		if !f.Nullable() {
+			return nil, fmt.Errorf("unable to redact field %q: the field can't be null", p)
+		}
+	}
+	return branches, nil
+}
+
+// checkKeep checks that all the fields of the record (found under the prefix
+// path) which neither match nor lead to any of the given paths can be removed.
+func checkKeep(s input.SchemaCodec, prefix string, paths []string) error {
+	rec, _ := s.Field(strings.TrimSuffix(prefix, "."))
+	for _, k := range rec.Fields {
+		p := prefix + k
+		exact, nested := false, false
+		for _, kp := range paths {
+			if kp == p {
+				exact = true
+			} else if strings.HasPrefix(kp, p+".") {
+				nested = true
+			}
+		}
+		if exact {
+			continue
+		}
+		if f, _ := s.Field(p); nested && len(f.Fields) > 0 {
+			if e := checkKeep(s, p+".", paths); e != nil {
+				return e
+			}
+			continue
+		}
+		if e := removable(s, p); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// field returns the schema of the field, or an error if it isn't defined.
+func field(s input.SchemaCodec, p string) (input.FieldSchema, error) {
+	f, found := s.Field(p)
+	if !found {
+		return f, fmt.Errorf("field %q is not defined in the schema", p)
+	}
+	return f, nil
+}
+
+// removable returns an error unless the field can be left out of the record.
+func removable(s input.SchemaCodec, p string) error {
+	f, e := field(s, p)
+	if e != nil {
+		return e
+	}
+	if !f.Optional {
+		return fmt.Errorf("field %q is required by the schema", p)
+	}
+	return nil
+}
+
+// branch returns the first of the types matching the constant value.
+func branch(v interface{}, types []string) (string, bool) {
+	for _, t := range types {
+		switch v := v.(type) {
+		case nil:
+			if t == "null" {
+				return t, true
+			}
+		case bool:
+			if t == "boolean" {
+				return t, true
+			}
+		case string:
+			if t == "string" {
+				return t, true
+			}
+		case float64:
+			if t == "float" || t == "double" || (t == "int" || t == "long") && v == math.Trunc(v) {
+				return t, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,221 @@
+package transform
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pburakov/playback/input"
+)
+
+// Rules describe field-level transformations of a record. Fields are addressed
+// by dot-separated paths, e.g. "user.email". Rules are applied in the
+// following order: keep, drop, rename, set, hash and redact, so that the paths
+// of the later rules refer to the renamed fields.
+type Rules struct {
+	// Keep lists the only fields retained in the record, if set.
+	Keep []string
+	// Drop lists fields removed from the record.
+	Drop []string
+	// Rename lists pairs of field paths and new field names within the same
+	// parent. Fields are renamed in the given order.
+	Rename [][2]string
+	// Set maps field paths to constant values. Values are parsed as JSON
+	// literals if possible and used as strings otherwise.
+	Set map[string]string
+	// Hash lists fields replaced with the SHA-256 hash of their value,
+	// prefixed with the salt. Numeric values are replaced with a non-negative
+	// number of the same type derived from the hash, other values with the
+	// hex-encoded hash.
+	Hash []string
+	Salt string
+	// Redact lists fields which values are replaced with null.
+	Redact []string
+}
+
+// Empty returns true if no transformations are defined.
+func (r *Rules) Empty() bool {
+	return len(r.Keep) == 0 && len(r.Drop) == 0 && len(r.Rename) == 0 &&
+		len(r.Set) == 0 && len(r.Hash) == 0 && len(r.Redact) == 0
+}
+
+// Apply transforms the record in place.
+func (r *Rules) Apply(rec map[string]interface{}) error {
+	_, e := r.apply(rec, nil)
+	return e
+}
+
+// apply transforms the record in place and returns the paths to hash or
+// redact which are missing in the record. Values to set are wrapped into Avro
+// unions of the given branches, by path.
+func (r *Rules) apply(rec map[string]interface{}, branches map[string]string) ([]string, error) {
+	if len(r.Keep) > 0 {
+		keep(rec, "", r.Keep)
+	}
+	for _, p := range r.Drop {
+		if m, k := input.Parent(rec, p); m != nil {
+			delete(m, k)
+		}
+	}
+	for _, rn := range r.Rename {
+		if m, k := input.Parent(rec, rn[0]); m != nil {
+			if v, found := m[k]; found {
+				delete(m, k)
+				m[rn[1]] = v
+			}
+		}
+	}
+	for p, v := range r.Set {
+		if e := set(rec, p, literal(v), branches[p]); e != nil {
+			return nil, e
+		}
+	}
+	var missing []string
+	for _, p := range r.Hash {
+		if !replace(rec, p, func(v interface{}) interface{} {
+			return hash(r.Salt, v)
+		}) {
+			missing = append(missing, p)
+		}
+	}
+	for _, p := range r.Redact {
+		if !replace(rec, p, func(v interface{}) interface{} {
+			return nil
+		}) {
+			missing = append(missing, p)
+		}
+	}
+	return missing, nil
+}
+
+// hash returns the salted SHA-256 hash of the value, keeping the type of
+// numeric values.
+func hash(salt string, v interface{}) interface{} {
+	h := sha256.Sum256([]byte(salt + fmt.Sprint(v)))
+	n := binary.BigEndian.Uint64(h[:8]) >> 1
+	switch v.(type) {
+	case json.Number:
+		return json.Number(strconv.FormatUint(n, 10))
+	case int:
+		return int(n)
+	case int32:
+		return int32(n >> 32)
+	case int64:
+		return int64(n)
+	case float32:
+		// keep within the exactly representable integers
+		return float32(n >> 39)
+	case float64:
+		return float64(n >> 10)
+	default:
+		return hex.EncodeToString(h[:])
+	}
+}
+
+// literal parses a constant value as a JSON literal, falling back to string.
+func literal(s string) interface{} {
+	var v interface{}
+	if e := json.Unmarshal([]byte(s), &v); e != nil {
+		return s
+	}
+	return v
+}
+
+// replace updates an existing field value using the given function and
+// returns false if the field is missing. Values wrapped into an Avro union are
+// replaced within the union.
+func replace(rec map[string]interface{}, path string, f func(interface{}) interface{}) bool {
+	m, k := input.Parent(rec, path)
+	if m == nil {
+		return false
+	}
+	v, found := m[k]
+	if !found {
+		return false
+	}
+	if inner, t, ok := input.Unwrap(v); ok && input.IsPrimitiveUnion(v) {
+		if nv := f(inner); nv != nil {
+			m[k] = map[string]interface{}{t: nv}
+			return true
+		}
+	}
+	m[k] = f(v)
+	return true
+}
+
+// set sets the field value, creating missing parent objects. Non-null values
+// are wrapped into an Avro union of the given branch, if any. Otherwise,
+// existing values wrapped into an Avro union are replaced within the union.
+func set(rec map[string]interface{}, path string, v interface{}, branch string) error {
+	segs := strings.Split(path, ".")
+	m := rec
+	for i := 0; i < len(segs)-1; i++ {
+		if _, found := m[segs[i]]; !found {
+			m[segs[i]] = make(map[string]interface{})
+		}
+		if m = input.Child(m, segs[i], segs[i+1]); m == nil {
+			return fmt.Errorf("unable to set field %q: %q is not an object", path, segs[i])
+		}
+	}
+	if len(branch) > 0 && v != nil {
+		m[segs[len(segs)-1]] = map[string]interface{}{branch: v}
+		return nil
+	}
+	replace(m, segs[len(segs)-1], func(interface{}) interface{} { return v })
+	if _, found := m[segs[len(segs)-1]]; !found {
+		m[segs[len(segs)-1]] = v
+	}
+	return nil
+}
+
+// keep removes all fields of the object (found under the prefix path) which
+// neither match nor lead to any of the given paths.
+func keep(m map[string]interface{}, prefix string, paths []string) {
+	for k, v := range m {
+		p := prefix + k
+		exact, nested := false, false
+		for _, kp := range paths {
+			if kp == p {
+				exact = true
+			} else if strings.HasPrefix(kp, p+".") {
+				nested = true
+			}
+		}
+		switch {
+		case exact:
+			continue
+		case nested:
+			if c, ok := v.(map[string]interface{}); ok {
+				if inner, _, ok := input.Unwrap(c); ok && isObject(inner) && !leadsTo(c, p, paths) {
+					c = inner.(map[string]interface{})
+				}
+				keep(c, p+".", paths)
+				continue
+			}
+			delete(m, k)
+		default:
+			delete(m, k)
+		}
+	}
+}
+
+// leadsTo returns true if any key of the object is part of the given paths.
+func leadsTo(m map[string]interface{}, prefix string, paths []string) bool {
+	for k := range m {
+		for _, kp := range paths {
+			if kp == prefix+"."+k || strings.HasPrefix(kp, prefix+"."+k+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isObject(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
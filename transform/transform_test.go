@@ -0,0 +1,178 @@
+package transform
+
+import (
+	encjson "encoding/json"
+	"testing"
+
+	"github.com/pburakov/playback/input"
+	"github.com/pburakov/playback/input/avro"
+	"github.com/pburakov/playback/input/json"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPayload = `{"id":"1","user":{"email":"foo@bar.com","name":"Foo"},"amount":42}`
+
+func TestApply(t *testing.T) {
+	rec := decode(t, testPayload)
+	rules := &Rules{
+		Drop:   []string{"user.name"},
+		Rename: [][2]string{{"user.email", "contact"}},
+		Set:    map[string]string{"env": "staging", "user.tier": "1", "source.name": `"replay"`},
+		Hash:   []string{"id"},
+		Redact: []string{"user.contact"},
+	}
+
+	assert.NoError(t, rules.Apply(rec))
+	assert.Equal(t, `{"amount":42,"env":"staging","id":"6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b",`+
+		`"source":{"name":"replay"},"user":{"contact":null,"tier":1}}`, encode(t, rec))
+}
+
+func TestApplyKeep(t *testing.T) {
+	rec := decode(t, testPayload)
+	rules := &Rules{Keep: []string{"id", "user.name"}}
+
+	assert.NoError(t, rules.Apply(rec))
+	assert.Equal(t, `{"id":"1","user":{"name":"Foo"}}`, encode(t, rec))
+}
+
+func TestApplyHashSalt(t *testing.T) {
+	rec := decode(t, testPayload)
+	rules := &Rules{Hash: []string{"amount"}, Salt: "salt"}
+
+	assert.NoError(t, rules.Apply(rec))
+	// numbers are hashed to numbers
+	assert.Equal(t, encjson.Number("6714297799355826043"), rec["amount"])
+}
+
+func TestApplyRenameOrder(t *testing.T) {
+	rec := decode(t, `{"a":"1","b":"2"}`)
+	rules := &Rules{Rename: [][2]string{{"b", "c"}, {"a", "b"}}, Hash: []string{"b"}}
+
+	assert.NoError(t, rules.Apply(rec))
+	assert.Equal(t, `{"b":"6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b","c":"2"}`, encode(t, rec))
+}
+
+func TestApplyErrors(t *testing.T) {
+	rec := decode(t, testPayload)
+	rules := &Rules{Set: map[string]string{"id.foo": "bar"}}
+
+	assert.Error(t, rules.Apply(rec))
+}
+
+func TestReaderJSON(t *testing.T) {
+	in, _ := json.Init("../input/json/input_test.json", "bar", "2006-01-02T15:04:05.999999")
+	r, e := Init(in, &Rules{Keep: []string{"foo", "faz.A"}})
+
+	assert.NoError(t, e)
+
+	_, l, e := r.ReadLineWithTS()
+
+	assert.NoError(t, e)
+	assert.Equal(t, `{"faz":{"A":"foo"},"foo":"1"}`, string(l))
+
+	l, e = r.ReadLine()
+
+	assert.NoError(t, e)
+	assert.Equal(t, `{"faz":{"A":"moo"},"foo":"2"}`, string(l))
+}
+
+func TestReaderMisses(t *testing.T) {
+	in, _ := json.Init("../input/json/input_test.json", "bar", "2006-01-02T15:04:05.999999")
+	r, e := Init(in, &Rules{Hash: []string{"foo", "user.email"}, Redact: []string{"faz.C"}})
+
+	assert.NoError(t, e)
+
+	_, e = r.ReadLine()
+	assert.NoError(t, e)
+	_, e = r.ReadLine()
+	assert.NoError(t, e)
+
+	assert.Equal(t, uint64(0), r.Misses("foo"))
+	assert.Equal(t, uint64(2), r.Misses("user.email"))
+	assert.Equal(t, uint64(2), r.Misses("faz.C"))
+	assert.NoError(t, r.Close())
+}
+
+func TestReaderAvro(t *testing.T) {
+	in, _ := avro.Init("../input/avro/input_test.avro", "bar", "2006-01-02T15:04:05.999999")
+	r, e := Init(in, &Rules{Hash: []string{"faz.A"}, Redact: []string{"foo"}, Drop: []string{"bar"}})
+
+	assert.NoError(t, e)
+
+	l, e := r.ReadLine()
+
+	assert.NoError(t, e)
+
+	rec, e := r.Codec().Decode(l)
+
+	assert.NoError(t, e)
+	assert.Nil(t, rec["foo"])
+	assert.Nil(t, rec["bar"])
+	assert.Equal(t, map[string]interface{}{"root.Faz": map[string]interface{}{
+		"A": map[string]interface{}{"string": "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+		"B": map[string]interface{}{"double": 42.42},
+	}}, rec["faz"])
+}
+
+func TestReaderAvroSchema(t *testing.T) {
+	in, _ := avro.Init("../input/avro/input_test.avro", "bar", "2006-01-02T15:04:05.999999")
+	r, e := Init(in, &Rules{Hash: []string{"foo"}, Set: map[string]string{"bar": `"baz"`}, Redact: []string{"faz.B"}})
+
+	assert.NoError(t, e)
+
+	l, e := r.ReadLine()
+
+	assert.NoError(t, e)
+
+	rec, e := r.Codec().Decode(l)
+
+	assert.NoError(t, e)
+	// the hashed long stays a long, set values are wrapped into the union
+	assert.Equal(t, map[string]interface{}{"long": int64(3874038210105081456)}, rec["foo"])
+	assert.Equal(t, map[string]interface{}{"string": "baz"}, rec["bar"])
+	assert.Nil(t, rec["faz"].(map[string]interface{})["root.Faz"].(map[string]interface{})["B"])
+}
+
+func TestReaderAvroSchemaErrors(t *testing.T) {
+	in, _ := avro.Init("../input/avro/input_test.avro", "bar", "2006-01-02T15:04:05.999999")
+
+	for _, rules := range []*Rules{
+		{Keep: []string{"foo"}},
+		{Keep: []string{"foo", "baz", "qux"}},
+		{Drop: []string{"baz"}},
+		{Drop: []string{"faz.A"}},
+		{Rename: [][2]string{{"foo", "qux"}}},
+		{Rename: [][2]string{{"foo", "bar"}}},
+		{Set: map[string]string{"qux": "1"}},
+		{Set: map[string]string{"foo": "bar"}},
+		{Set: map[string]string{"faz.A": "bar"}},
+		{Hash: []string{"baz"}},
+		{Hash: []string{"qux"}},
+		{Redact: []string{"baz"}},
+	} {
+		_, e := Init(in, rules)
+		assert.Error(t, e, "%+v", rules)
+	}
+
+	_, e := Init(in, &Rules{Keep: []string{"foo", "baz"}, Set: map[string]string{"foo": "42", "bar": "null"}})
+	assert.NoError(t, e)
+}
+
+func TestInitErrors(t *testing.T) {
+	r, e := Init(nil, &Rules{})
+
+	assert.Error(t, e)
+	assert.Nil(t, r)
+}
+
+func decode(t *testing.T, s string) map[string]interface{} {
+	rec, e := input.JSONCodec{}.Decode([]byte(s))
+	assert.NoError(t, e)
+	return rec
+}
+
+func encode(t *testing.T, rec map[string]interface{}) string {
+	d, e := input.JSONCodec{}.Encode(rec)
+	assert.NoError(t, e)
+	return string(d)
+}
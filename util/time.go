@@ -11,9 +11,10 @@ func DefaultTimestamp() time.Time {
 }
 
 // Jitter calculates random jitter duration value that deviates within given max
-// jitter constraint, as a negative and positive offset from zero.
-func Jitter(maxJitterMSec int) time.Duration {
-	return time.Duration(float64(maxJitterMSec) * ((rand.Float64() - 0.5) * 2) * 1000000)
+// jitter constraint, as a negative and positive offset from zero. Random values
+// are drawn from the given source.
+func Jitter(r *rand.Rand, maxJitterMSec int) time.Duration {
+	return time.Duration(float64(maxJitterMSec) * ((r.Float64() - 0.5) * 2) * 1000000)
 }
 
 // MSecToDuration converts integer millisecond value to duration value.
@@ -9,24 +9,33 @@ import (
 )
 
 func TestJitter(t *testing.T) {
-	rand.Seed(time.Now().Unix())
+	r := rand.New(rand.NewSource(time.Now().Unix()))
 
-	j := Jitter(100)
+	j := Jitter(r, 100)
 
 	assert.True(t, j.Nanoseconds() < 100*1000000)
 	assert.True(t, -100*1000000 < j.Nanoseconds())
 
-	j = Jitter(100)
+	j = Jitter(r, 100)
 
 	assert.True(t, j.Nanoseconds() < 100*1000000)
 	assert.True(t, -100*1000000 < j.Nanoseconds())
 
-	j = Jitter(100)
+	j = Jitter(r, 100)
 
 	assert.True(t, j.Nanoseconds() < 100*1000000)
 	assert.True(t, -100*1000000 < j.Nanoseconds())
 }
 
+func TestJitterSeed(t *testing.T) {
+	r1 := rand.New(rand.NewSource(42))
+	r2 := rand.New(rand.NewSource(42))
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, Jitter(r1, 100), Jitter(r2, 100))
+	}
+}
+
 func TestMSecToDuration(t *testing.T) {
 	assert.Equal(t, 42*time.Millisecond, MSecToDuration(42))
 	assert.Equal(t, -42*time.Millisecond, MSecToDuration(-42))
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSConfig returns the client TLS configuration with the optional CA
+// certificate file, used instead of the system roots, and the optional client
+// certificate and key files. Skipping the verification of the server
+// certificate is only meant for testing.
+func TLSConfig(caFile string, certFile string, keyFile string, insecure bool) (*tls.Config, error) {
+	c := &tls.Config{InsecureSkipVerify: insecure}
+	if len(caFile) > 0 {
+		b, e := ioutil.ReadFile(caFile)
+		if e != nil {
+			return nil, e
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %q", caFile)
+		}
+	}
+	if len(certFile) > 0 || len(keyFile) > 0 {
+		cert, e := tls.LoadX509KeyPair(certFile, keyFile)
+		if e != nil {
+			return nil, fmt.Errorf("error loading client certificate: %s", e)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
@@ -0,0 +1,20 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSConfig(t *testing.T) {
+	c, e := TLSConfig("", "", "", true)
+	assert.NoError(t, e)
+	assert.True(t, c.InsecureSkipVerify)
+	assert.Nil(t, c.RootCAs)
+
+	_, e = TLSConfig("missing.pem", "", "", false)
+	assert.Error(t, e)
+
+	_, e = TLSConfig("", "cert.pem", "", false)
+	assert.Error(t, e)
+}
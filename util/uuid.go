@@ -0,0 +1,22 @@
+package util
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// UUID returns a random (version 4) UUID string drawn from the given random
+// source, so that seeded sources produce reproducible UUIDs.
+func UUID(r *rand.Rand) string {
+	var b [16]byte
+	_, _ = r.Read(b[:])
+	return FormatUUID(b)
+}
+
+// FormatUUID formats the random bytes as a version 4 UUID string, overwriting
+// the version and variant bits.
+func FormatUUID(b [16]byte) string {
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,33 @@
+package util
+
+import (
+	"math/rand"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUUID(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	r := rand.New(rand.NewSource(1))
+	u1, u2 := UUID(r), UUID(r)
+
+	assert.Regexp(t, re, u1)
+	assert.Regexp(t, re, u2)
+	assert.NotEqual(t, u1, u2)
+
+	// the same seed produces the same UUIDs
+	assert.Equal(t, u1, UUID(rand.New(rand.NewSource(1))))
+}
+
+func TestFormatUUID(t *testing.T) {
+	var b [16]byte
+	for i := range b {
+		b[i] = 0xff
+	}
+
+	assert.Equal(t, "ffffffff-ffff-4fff-bfff-ffffffffffff", FormatUUID(b))
+	assert.Equal(t, "00000000-0000-4000-8000-000000000000", FormatUUID([16]byte{}))
+}